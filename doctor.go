@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/axilock/axi/doctor"
+	"github.com/axilock/axi/internal/config"
+	"github.com/fatih/color"
+)
+
+// Kong bindings
+type DoctorCmd struct {
+	JSON bool `help:"Print results as json" default:"false"`
+	Fix  bool `help:"Repair failed checks, then check again" default:"false"`
+	Yes  bool `help:"Apply destructive fixes without asking" default:"false"`
+}
+
+func (c *DoctorCmd) Run(cfg *config.Config, ret *int) error {
+	d := doctor.New(cfg)
+	results := d.Run()
+
+	var repairs []doctor.Repair
+	if c.Fix {
+		stdin := bufio.NewReader(os.Stdin)
+		repairs = d.Repair(results, func(fix *doctor.Fix) bool {
+			if c.Yes {
+				return true
+			}
+			fmt.Fprintf(os.Stderr, "%s? [y/N] ", fix.Description)
+			answer, _ := stdin.ReadString('\n')
+			answer = strings.ToLower(strings.TrimSpace(answer))
+			return answer == "y" || answer == "yes"
+		})
+		results = d.Run()
+	}
+
+	overall := doctor.Overall(results)
+	if overall == doctor.Fail {
+		*ret = 1
+	}
+
+	if c.JSON {
+		out, err := json.MarshalIndent(struct {
+			Status  doctor.Status   `json:"status"`
+			Version string          `json:"version"`
+			Repairs []doctor.Repair `json:"repairs,omitempty"`
+			Checks  []doctor.Result `json:"checks"`
+		}{overall, cfg.Version, repairs, results}, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+
+	for _, repair := range repairs {
+		switch {
+		case repair.Applied:
+			fmt.Printf("%s %s: %s\n", color.GreenString("[FIXED]"), repair.Check, repair.Fix)
+		case repair.Error != "":
+			fmt.Printf("%s %s: %s: %s\n", color.RedString("[ERROR]"), repair.Check, repair.Fix, repair.Error)
+		default:
+			fmt.Printf("%s %s: %s\n", color.YellowString("[SKIPPED]"), repair.Check, repair.Fix)
+		}
+	}
+	if len(repairs) > 0 {
+		fmt.Println()
+	}
+
+	labels := map[doctor.Status]string{
+		doctor.Pass: color.GreenString("[PASS]"),
+		doctor.Warn: color.YellowString("[WARN]"),
+		doctor.Fail: color.RedString("[FAIL]"),
+	}
+	for _, result := range results {
+		fmt.Printf("%s %s: %s\n", labels[result.Status], result.Check, result.Message)
+		if result.Hint != "" {
+			fmt.Printf("       hint: %s\n", result.Hint)
+		}
+		if result.Fix != nil && !c.Fix {
+			fmt.Printf("       fix: %s (axi doctor --fix)\n", result.Fix.Description)
+		}
+	}
+	fmt.Println("\nOverall: " + strings.ToUpper(string(overall)))
+	return nil
+}
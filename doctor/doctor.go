@@ -0,0 +1,423 @@
+package doctor
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/axilock/axi/hooks"
+	"github.com/axilock/axi/installer"
+	"github.com/axilock/axi/internal/config"
+	"github.com/axilock/axi/internal/context"
+	"github.com/axilock/axi/internal/filesio"
+	"github.com/axilock/axi/internal/git"
+	pb "github.com/axilock/axilock-protos/client"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type Status string
+
+const (
+	Pass Status = "pass"
+	Warn Status = "warn"
+	Fail Status = "fail"
+)
+
+type Result struct {
+	Check   string `json:"check"`
+	Status  Status `json:"status"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+	Fix     *Fix   `json:"fix,omitempty"` // nil if it can't be fixed automatically
+}
+
+// Fix repairs what a check found
+type Fix struct {
+	Description string `json:"description"`
+	// Destructive fixes discard user configuration and need confirmation
+	Destructive bool `json:"destructive"`
+	apply       func() error
+}
+
+// Repair is the outcome of a fix
+type Repair struct {
+	Check   string `json:"check"`
+	Fix     string `json:"fix"`
+	Applied bool   `json:"applied"`
+	Error   string `json:"error,omitempty"`
+}
+
+type check struct {
+	name string
+	run  func() Result
+}
+
+// Doctor diagnoses an axi installation, and the current repo
+// if run from inside one
+type Doctor struct {
+	cfg    *config.Config
+	afs    filesio.AxiFS
+	layout *git.Layout // nil outside a git repo
+}
+
+// requests to the backend, a doctor run should not hang on them
+const requestTimeout = 15 * time.Second
+
+var versionRegex = regexp.MustCompile(`\d+\.\d+\.\d+`)
+
+func New(cfg *config.Config) *Doctor {
+	d := &Doctor{cfg: cfg, afs: filesio.AxiFS{Home: cfg.Home()}}
+	if layout, err := git.ResolveLayout(); err == nil {
+		d.layout = &layout
+	}
+	return d
+}
+
+func (d *Doctor) checks() []check {
+	checks := []check{
+		{"config", d.checkConfig},
+		{"global-hooks-path", d.checkGlobalHooksPath},
+		{"hook-symlinks", d.checkHookSymlinks},
+		{"trufflehog", d.checkTrufflehog},
+		{"api-key", d.checkAPIKey},
+		{"backend", d.checkBackend},
+	}
+	if disabled, _ := git.GetLocalDisabled(); d.layout != nil && disabled {
+		checks = append(checks, check{"repo-protection", d.checkDisabledRepo})
+	} else if d.layout != nil {
+		checks = append(checks,
+			// pre-push first, its fix makes way for the local hooks path one
+			check{"pre-push-hook", d.checkPrePushHook},
+			check{"local-hooks-path", d.checkLocalHooksPath},
+		)
+	}
+	return checks
+}
+
+// Run runs all checks, in order
+func (d *Doctor) Run() []Result {
+	var results []Result
+	for _, check := range d.checks() {
+		result := check.run()
+		result.Check = check.name
+		results = append(results, result)
+	}
+	return results
+}
+
+// Repair applies the fixes of results which did not pass, in order.
+// Destructive fixes are applied only if confirm returns true.
+// Run the checks again to see the outcome.
+func (d *Doctor) Repair(results []Result, confirm func(*Fix) bool) []Repair {
+	var logger = context.Background().Logger()
+
+	var repairs []Repair
+	for _, result := range results {
+		if result.Status == Pass || result.Fix == nil {
+			continue
+		}
+		repair := Repair{Check: result.Check, Fix: result.Fix.Description}
+		if result.Fix.Destructive && !confirm(result.Fix) {
+			repairs = append(repairs, repair)
+			continue
+		}
+		if err := result.Fix.apply(); err != nil {
+			logger.Error(err, "Fix failed: "+result.Fix.Description)
+			repair.Error = err.Error()
+		} else {
+			repair.Applied = true
+		}
+		repairs = append(repairs, repair)
+	}
+	return repairs
+}
+
+// Overall is the worst status of results
+func Overall(results []Result) Status {
+	overall := Pass
+	for _, result := range results {
+		switch {
+		case result.Status == Fail:
+			return Fail
+		case result.Status == Warn:
+			overall = Warn
+		}
+	}
+	return overall
+}
+
+func (d *Doctor) reinstallHint() string {
+	return "Run `" + d.afs.BinaryPath() + " reinstall`"
+}
+
+func (d *Doctor) checkConfig() Result {
+	file, err := d.cfg.CheckRuntimeYAML()
+	if err != nil {
+		return Result{
+			Status:  Fail,
+			Message: file + ": " + err.Error(),
+			Hint:    "Fix " + file,
+		}
+	}
+	if file == "" {
+		return Result{Status: Pass, Message: "No config file, using defaults"}
+	}
+	return Result{Status: Pass, Message: file + " is valid"}
+}
+
+func (d *Doctor) checkGlobalHooksPath() Result {
+	dir, err := git.GetGlobalCoreHooksPath()
+	if err != nil {
+		return Result{Status: Fail, Message: "Could not read git config: " + err.Error()}
+	}
+
+	hint := "Run `git config --global core.hooksPath " + d.afs.HooksDir() + "`"
+	fix := &Fix{
+		Description: "Set global core.hooksPath to " + d.afs.HooksDir(),
+		apply:       func() error { return git.SetGlobalCoreHooksPath(d.afs.HooksDir()) },
+	}
+	switch dir {
+	case d.afs.HooksDir():
+		return Result{Status: Pass, Message: "core.hooksPath is " + dir}
+	case "":
+		return Result{Status: Fail, Message: "Global core.hooksPath is not set, axi does not run on git hooks", Hint: hint, Fix: fix}
+	}
+	fix.Description += ", hooks in " + dir + " stop running"
+	fix.Destructive = true
+	return Result{
+		Status:  Fail,
+		Message: "Global core.hooksPath is " + dir + ", axi does not run on git hooks",
+		Hint:    hint + ". Hooks in " + dir + " will no longer run",
+		Fix:     fix,
+	}
+}
+
+func (d *Doctor) checkHookSymlinks() Result {
+	binary := d.afs.BinaryPath()
+	fix := &Fix{
+		Description: "Restore axi binary and hook links in " + d.afs.HooksDir(),
+		apply:       func() error { return installer.RepairHooks(d.cfg.Home()) },
+	}
+	if !filesio.FileExists(binary) {
+		return Result{Status: Fail, Message: "axi binary missing: " + binary, Hint: "Reinstall axi", Fix: fix}
+	}
+
+	var broken []string
+	for _, name := range git.HookNames {
+		target, err := os.Readlink(filepath.Join(d.afs.HooksDir(), name))
+		if err != nil || filepath.Clean(target) != binary {
+			broken = append(broken, name)
+		}
+	}
+	if len(broken) > 0 {
+		return Result{
+			Status:  Fail,
+			Message: "Hooks missing or not linked to " + binary + ": " + strings.Join(broken, ", "),
+			Hint:    d.reinstallHint(),
+			Fix:     fix,
+		}
+	}
+	return Result{Status: Pass, Message: "All hooks in " + d.afs.HooksDir() + " link to " + binary}
+}
+
+func (d *Doctor) checkTrufflehog() Result {
+	path := d.cfg.TrufflehogPath()
+	fix := &Fix{
+		Description: "Download trufflehog " + installer.TrufflehogVersion,
+		apply: func() error {
+			if err := os.MkdirAll(d.afs.BinaryDir(), 0755); err != nil {
+				return err
+			}
+			return installer.InstallTrufflehog(d.cfg.Home())
+		},
+	}
+	if !filesio.FileExists(path) {
+		return Result{Status: Fail, Message: "trufflehog not found at " + path, Hint: d.reinstallHint(), Fix: fix}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, "--version").CombinedOutput()
+	if err != nil {
+		return Result{Status: Fail, Message: "trufflehog does not run: " + err.Error(), Hint: d.reinstallHint(), Fix: fix}
+	}
+
+	version := versionRegex.FindString(string(out))
+	if version != installer.TrufflehogVersion {
+		return Result{
+			Status:  Warn,
+			Message: "trufflehog " + version + " installed, axi expects " + installer.TrufflehogVersion,
+			Hint:    d.reinstallHint(),
+			Fix:     fix,
+		}
+	}
+	return Result{Status: Pass, Message: "trufflehog " + version}
+}
+
+func (d *Doctor) checkAPIKey() Result {
+	hint := "Run `" + d.afs.BinaryPath() + " auth`"
+	key, err := d.afs.APIKey()
+	if err != nil || key == "" {
+		return Result{Status: Fail, Message: "No api key at " + d.afs.APIKeyPath(), Hint: hint}
+	}
+	if d.cfg.Offline {
+		return Result{Status: Pass, Message: "Api key present, not verified in offline mode"}
+	}
+
+	conn, err := d.cfg.FreshGRPCConn()
+	if err != nil {
+		return Result{Status: Warn, Message: "Could not verify api key: " + err.Error()}
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.WithGrpcMetadata(context.Background()), requestTimeout)
+	defer cancel()
+	_, err = pb.NewHealthServiceClient(conn).DoHealthCheck(context.WithAuth(ctx, key), &pb.HealthRequest{
+		ClientVer: d.cfg.Version,
+		AuthToken: key,
+	})
+	switch status.Code(err) {
+	case codes.OK:
+		return Result{Status: Pass, Message: "Api key accepted by backend"}
+	case codes.Unauthenticated, codes.PermissionDenied:
+		return Result{Status: Fail, Message: "Api key rejected by backend", Hint: hint}
+	}
+	return Result{Status: Warn, Message: "Could not verify api key: " + err.Error()}
+}
+
+func (d *Doctor) checkBackend() Result {
+	endpoint := d.cfg.GRPCEndpoint()
+	if d.cfg.Offline {
+		return Result{Status: Warn, Message: "Offline mode, " + endpoint + " not checked"}
+	}
+
+	conn, err := d.cfg.FreshGRPCConn()
+	if err != nil {
+		return Result{Status: Fail, Message: "Could not connect to " + endpoint + ": " + err.Error()}
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.WithGrpcMetadata(context.Background()), requestTimeout)
+	defer cancel()
+	_, err = pb.NewHealthServiceClient(conn).ClientUpdateRpc(ctx, &pb.ClientUpdateRequest{ClientVer: d.cfg.Version})
+
+	switch status.Code(err) {
+	case codes.OK, codes.Unauthenticated, codes.PermissionDenied:
+		// reachable, auth is checked separately
+		if !d.cfg.GRPCTLs {
+			return Result{Status: Warn, Message: endpoint + " reachable without TLS", Hint: "Set `grpc_tls: true` in ~/.axi/config.yaml"}
+		}
+		return Result{Status: Pass, Message: endpoint + " reachable over TLS"}
+	case codes.DeadlineExceeded:
+		return Result{Status: Fail, Message: endpoint + " timed out", Hint: "Check your network, VPN or proxy"}
+	}
+
+	msg := err.Error()
+	if strings.Contains(msg, "x509") || strings.Contains(msg, "certificate") || strings.Contains(msg, "tls:") {
+		return Result{
+			Status:  Fail,
+			Message: "TLS handshake with " + endpoint + " failed: " + status.Convert(err).Message(),
+			Hint:    "Check grpc_server_name and grpc_tls in ~/.axi/config.yaml, and that your system trusts the server's certificate",
+		}
+	}
+	return Result{
+		Status:  Fail,
+		Message: endpoint + " unreachable: " + status.Convert(err).Message(),
+		Hint:    "Check your network, VPN or proxy",
+	}
+}
+
+func (d *Doctor) checkLocalHooksPath() Result {
+	local, err := git.GetLocalCoreHooksPath()
+	if err != nil {
+		return Result{Status: Fail, Message: "Could not read git config: " + err.Error()}
+	}
+
+	setup := func() error { return hooks.SetupRepo(d.cfg.Home(), d.cfg.HookChainPolicy) }
+	localHooksDir := d.layout.HooksDir()
+	switch {
+	case local == "":
+		return Result{Status: Pass, Message: "Not set, axi's global hooks set up this repo on the next git hook run"}
+	case d.layout.IsHooksDir(local, localHooksDir):
+		return Result{Status: Pass, Message: "core.hooksPath is " + local}
+	}
+
+	if manager := hooks.HooksDirManager(d.layout.Abs(local)); manager != "" {
+		return Result{
+			Status:  Fail,
+			Message: "core.hooksPath set to " + local + " by " + string(manager) + ", axi does not run in this repo",
+			Hint:    "Call `" + d.afs.BinaryPath() + ` hook pre-push "$@"` + "` from " + string(manager) + "'s pre-push hook",
+			Fix: &Fix{
+				Description: "Run axi's hooks in this repo, chaining " + string(manager) + "'s from " + local,
+				apply:       setup,
+			},
+		}
+	}
+	return Result{
+		Status:  Fail,
+		Message: "core.hooksPath set to " + local + ", axi does not run in this repo",
+		Hint:    "Run `git config --local --unset core.hooksPath`. Hooks in " + local + " will no longer run",
+		Fix: &Fix{
+			Description: "Run axi's hooks in this repo, hooks in " + local + " stop running",
+			Destructive: true,
+			apply: func() error {
+				if err := git.UnsetLocalCoreHooksPath(); err != nil {
+					return err
+				}
+				return setup()
+			},
+		},
+	}
+}
+
+func (d *Doctor) checkDisabledRepo() Result {
+	return Result{
+		Status:  Warn,
+		Message: "Protection disabled in this repo, pushes are not scanned",
+		Hint:    "Run `" + d.afs.BinaryPath() + " repos enable`",
+	}
+}
+
+func (d *Doctor) checkPrePushHook() Result {
+	err := hooks.CheckAxiHook(d.cfg.Home(), "pre-push", d.layout.HooksDir(), d.cfg.HookChainPolicy)
+
+	setup := &Fix{
+		Description: "Install axi's pre-push script",
+		apply:       func() error { return hooks.UpdatePrePushHook(d.cfg.Home(), d.cfg.HookChainPolicy) },
+	}
+	var outdated *hooks.ErrOutdatedHook
+	var corrupted *hooks.ErrCorruptedHook
+	switch {
+	case err == nil:
+		return Result{Status: Pass, Message: "pre-push script is up to date"}
+	case errors.Is(err, os.ErrNotExist):
+		return Result{Status: Warn, Message: "pre-push script not installed yet, it is on the next git hook run", Fix: setup}
+	case errors.As(err, &outdated):
+		setup.Description = "Update axi's pre-push script"
+		return Result{Status: Warn, Message: "pre-push script is outdated: " + outdated.Path + ", it is updated on the next push", Fix: setup}
+	case errors.As(err, &corrupted):
+		path := corrupted.Path
+		return Result{
+			Status:  Fail,
+			Message: "pre-push hook is not axi's: " + path,
+			Hint:    "Run `mv " + path + " " + path + ".user` to keep it running after axi's scan",
+			Fix: &Fix{
+				Description: "Keep " + path + " as a user hook running after axi's scan, install axi's pre-push script",
+				apply: func() error {
+					moved, err := hooks.PreserveUserHook(path)
+					if err != nil {
+						return err
+					}
+					context.Background().Logger().Info("Moved " + path + " to " + moved)
+					return setup.apply()
+				},
+			},
+		}
+	}
+	return Result{Status: Fail, Message: "Could not check pre-push hook: " + err.Error()}
+}
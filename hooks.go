@@ -1,13 +1,16 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strconv"
 
 	"github.com/axilock/axi/hooks"
 	"github.com/axilock/axi/internal/config"
 	"github.com/axilock/axi/internal/context"
 	"github.com/axilock/axi/internal/git"
+	"github.com/axilock/axi/internal/utils"
 	"github.com/axilock/axi/scanner"
 	pb "github.com/axilock/axilock-protos/client"
 	"google.golang.org/grpc"
@@ -35,11 +38,18 @@ func (h *HookCmd) Run(
 		if len(h.Args) != 2 {
 			return fmt.Errorf("pre-push hook requires 2 arguments")
 		}
+		if disabled, _ := git.GetLocalDisabled(); disabled {
+			logger.Info("Protection disabled in this repo, push not scanned")
+			return nil
+		}
 		hook := hooks.NewPrePushHook(
 			cfg.Home(),
-			scanner.NewTrufflehog(cfg.TrufflehogPath()),
-		)
-		repo := h.Args[1]
+			newSecretScanner(cfg),
+		).WithSubmoduleScan(cfg.ScanSubmodules).
+			WithScanTimeout(cfg.ScanTimeout).
+			WithChainPolicy(cfg.HookChainPolicy)
+		// never report credentials embedded in the remote url
+		repo := git.CanonicalRepoURL(h.Args[1])
 		out, err := hook.Run(h.Args[0], repo)
 		if err != nil {
 			return err
@@ -56,6 +66,25 @@ func (h *HookCmd) Run(
 			logger.V(1).Info("No commits to send")
 		}
 
+		if msg := out.CoverageMessage(); msg != "" {
+			logger.Info(msg)
+			fmt.Fprint(os.Stderr, msg)
+		}
+
+		if len(out.ScanErrors) > 0 {
+			blocked := cfg.OnScanFailure == config.FailClosed
+			if blocked {
+				*ret = 1
+			}
+			fmt.Fprint(os.Stderr, out.ScanFailureMessage(blocked))
+			if !cfg.Offline {
+				err := sendScanFailure(conn, cfg.Version, repo, out.ScanErrors, blocked)
+				if err != nil {
+					logger.Error(err, err.Error())
+				}
+			}
+		}
+
 		if len(out.Secrets) > 0 {
 			*ret = 1
 			if !cfg.Offline {
@@ -74,6 +103,46 @@ func (h *HookCmd) Run(
 	return &hooks.ErrUnsupportedHook{Name: string(h.Name)}
 }
 
+func newSecretScanner(cfg *config.Config) scanner.SecretScanner {
+	logger := context.Background().Logger()
+
+	trufflehog := scanner.NewTrufflehog(cfg.TrufflehogPath())
+	if cfg.ScanMode == config.ScanDiff {
+		return scanner.NewDiffScanner(trufflehog, cfg.DiffFindRenames)
+	}
+
+	// history scans read every blob in range, which partial clones
+	// would have to fetch. Scan only what is available locally instead.
+	if layout, err := git.ResolveLayout(); err == nil && layout.Promisor {
+		logger.Info("Partial clone detected, using diff scan mode")
+		return scanner.NewDiffScanner(trufflehog, cfg.DiffFindRenames)
+	}
+	return trufflehog
+}
+
+func sendScanFailure(conn *grpc.ClientConn, version, repo string, scanErrors []error, blocked bool) error {
+	client := pb.NewMetadataServiceClient(conn)
+
+	details := map[string]string{
+		"blocked": strconv.FormatBool(blocked),
+		"error":   errors.Join(scanErrors...).Error(),
+	}
+	var timeout *scanner.ErrScanTimeout
+	for _, err := range scanErrors {
+		if errors.As(err, &timeout) {
+			details["timeout"] = timeout.Timeout.String()
+		}
+	}
+
+	ctx, cancel := context.GRPCContext()
+	defer cancel()
+	_, err := client.RepoMetadata(ctx, &pb.MetadataRepoRequest{
+		RepoUrl:  repo,
+		Metadata: utils.GetEventMetadataJson(version, "scan_failure", details),
+	})
+	return err
+}
+
 func sendSecretAlerts(conn *grpc.ClientConn, repo string, secrets []scanner.Secret) error {
 	logger := context.Background().Logger()
 
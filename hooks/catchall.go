@@ -1,12 +1,11 @@
 package hooks
 
 import (
-	"slices"
-
 	"github.com/axilock/axi/internal/config"
 	"github.com/axilock/axi/internal/context"
 	"github.com/axilock/axi/internal/filesio"
 	"github.com/axilock/axi/internal/git"
+	"github.com/axilock/axi/internal/registry"
 	"github.com/axilock/axi/internal/utils"
 	pb "github.com/axilock/axilock-protos/client"
 	"google.golang.org/grpc"
@@ -23,16 +22,17 @@ func (e CorruptedPrePushError) Error() string {
 func Catchall(conn *grpc.ClientConn, cfg *config.Config, home, name, version string, args ...string) error {
 	var logger = context.Background().Logger()
 
-	if !git.IsInsideGitRepo() {
+	layout, err := git.ResolveLayout()
+	if err != nil {
 		// possible git init invocation
 		return nil
 	}
-
-	localHooksDir, err := getLocalHooksDir()
-	if err != nil {
-		return err
+	if !layout.Bare && layout.TopLevel == "" {
+		// invoked from inside the git dir of a non bare repo
+		return nil
 	}
 
+	localHooksDir := layout.HooksDir()
 	logger.V(1).Info("Local hooks dir: " + localHooksDir)
 
 	if !filesio.DirExists(localHooksDir) {
@@ -45,34 +45,40 @@ func Catchall(conn *grpc.ClientConn, cfg *config.Config, home, name, version str
 		return err
 	}
 
-	localHooksDirRelToGitToplevel, err := git.DirRelToGitTopLevel(localHooksDir)
+	disabled, err := git.GetLocalDisabled()
 	if err != nil {
 		return err
 	}
-	if hooksDir == localHooksDirRelToGitToplevel {
+
+	if layout.Abs(hooksDir) == localHooksDir {
 		// Multiple runs triggered
 		// possibly due to hooks like reference-transaction etc
 		// TODO: something...
+	} else if disabled {
+		logger.V(1).Info("Protection disabled in this repo, not setting it up")
 	} else {
 
-		if err := installAxiHook(home, localHooksDir); err != nil {
+		if err := installAxiHook(home, layout, cfg.HookChainPolicy); err != nil {
 			return err
 		}
 
 		// FIXME: Highlight this!!
-		if err := git.SetLocalCoreHooksPath(localHooksDirRelToGitToplevel); err != nil {
+		if err := git.SetLocalCoreHooksPath(layout.HooksPathConfig(localHooksDir)); err != nil {
 			logger.Error(err, "Could not deregsiter global hooks. User local hooks will not work!")
 			return err
 		}
+		enroll(home, layout, repoURL(name, args))
 
 		if !cfg.Offline {
-			metadata := utils.GetSystemMetadataJson(version)
+			remotes, err := git.Remotes()
+			if err != nil {
+				logger.Error(err, "Could not list remotes")
+			}
+			metadata := utils.GetRepoMetadataJson(version, remotes)
 			client := pb.NewMetadataServiceClient(conn)
 
-			// FIXME: what if remote is not origin ? #7
-			repourl, _ := git.GetRemoteUrl("origin")
-			_, err := client.RepoMetadata(context.Background(), &pb.MetadataRepoRequest{
-				RepoUrl:  repourl,
+			_, err = client.RepoMetadata(context.Background(), &pb.MetadataRepoRequest{
+				RepoUrl:  repoURL(name, args),
 				Metadata: metadata,
 			})
 			if err != nil {
@@ -81,34 +87,88 @@ func Catchall(conn *grpc.ClientConn, cfg *config.Config, home, name, version str
 		}
 	}
 
-	hook := Hook{Name: name}
+	hook := Hook{Name: name, Policy: cfg.HookChainPolicy}
 	if err := hook.RunIfExists(args...); err != nil {
 		return err
 	}
 
-	// TODO: Store this repo path locally for uninstallation
 	return nil
 }
 
-func installAxiHook(home, localHooksDir string) error {
+// SetupRepo installs axi's pre-push script in the current repo and
+// points its local core.hooksPath at it, as the first hook run does
+func SetupRepo(home string, policy config.ChainPolicy) error {
+	layout, err := git.ResolveLayout()
+	if err != nil {
+		return err
+	}
+	if err := installAxiHook(home, layout, policy); err != nil {
+		return err
+	}
+	if err := git.SetLocalCoreHooksPath(layout.HooksPathConfig(layout.HooksDir())); err != nil {
+		return err
+	}
+	enroll(home, layout, git.PushRepoURL())
+	return nil
+}
+
+// enroll records the repo in axi's registry (see registry.Registry),
+// failing to do so does not fail the hook
+func enroll(home string, layout git.Layout, remote string) {
 	var logger = context.Background().Logger()
 
-	localHooksDirRelToGitTopLevel, err := git.DirRelToGitTopLevel(localHooksDir)
+	repo, err := registry.New(home).Enroll(layout, remote)
 	if err != nil {
-		return err
+		logger.Error(err, "Could not record repo in registry")
+		return
 	}
+	logger.V(1).Info("Repo enrolled: " + repo.ID + " " + repo.Path)
+}
+
+// repoURL identifies the repo by the url being pushed to, or the url
+// of the remote git would push the current branch to.
+// Returned url is canonical and credential free.
+func repoURL(hook string, args []string) string {
+	if hook == "pre-push" && len(args) == 2 {
+		return git.CanonicalRepoURL(args[1])
+	}
+	return git.PushRepoURL()
+}
 
+func installAxiHook(home string, layout git.Layout, policy config.ChainPolicy) error {
+	var logger = context.Background().Logger()
+
+	localHooksDir := layout.HooksDir()
 	logger.V(1).Info("Local hooks dir: " + localHooksDir)
-	logger.V(1).Info("Local hooks dir relative to git top level: " + localHooksDirRelToGitTopLevel)
+	logger.V(1).Info("Local hooks dir as per config: " + layout.HooksPathConfig(localHooksDir))
+
+	for _, manager := range DetectHookManagers(layout) {
+		logger.V(1).Info("Hook manager configured: " + string(manager))
+	}
+
+	// a hook manager (husky) set the local hooks dir to its own,
+	// keep running its hooks from axi's
+	local, err := git.GetLocalCoreHooksPath()
+	if err != nil {
+		return err
+	}
+	if local != "" && !layout.IsHooksDir(local, localHooksDir) {
+		if manager := HooksDirManager(layout.Abs(local)); manager != "" {
+			if err := chainHookManager(layout, local, manager); err != nil {
+				return err
+			}
+		}
+	}
 
 	afs := filesio.AxiFS{Home: home}
-	if err := assertHooksDirs(localHooksDirRelToGitTopLevel, afs.HooksDir()); err != nil {
+	if err := assertHooksDirs(layout, afs.HooksDir()); err != nil {
 		return err
 	}
 
 	if err := createOrUpdatePrePushHook(
 		home,
 		localHooksDir,
+		policy,
 	); err != nil {
 		logger.Error(err, "Could not validate existing local pre push hook")
 		return err
@@ -118,13 +178,16 @@ func installAxiHook(home, localHooksDir string) error {
 	return nil
 }
 
-func assertHooksDirs(local, global string) error {
+// assertHooksDirs ensures git currently uses either the local hooks dir
+// or axi's global hooks dir
+func assertHooksDirs(layout git.Layout, global string) error {
+	local := layout.HooksDir()
 	dir, err := git.GetCoreHooksPath()
-	expectedDirs := []string{local, global}
+	expectedDirs := []string{layout.HooksPathConfig(local), global}
 	if err != nil {
 		return err
 	}
-	if slices.Contains(expectedDirs, dir) {
+	if layout.IsHooksDir(dir, local) || layout.IsHooksDir(dir, global) {
 		return nil
 	}
 
@@ -132,6 +195,10 @@ func assertHooksDirs(local, global string) error {
 	if err != nil {
 		return err
 	}
+	if chained, _ := git.GetLocalChainHooksPath(); dir != "" && dir == chained {
+		// hook manager's dir, replaced by the local hooks dir next
+		return nil
+	}
 	if dir != "" {
 		return &ErrUnsupportedConfiguration{Current: dir,
 			Expected: expectedDirs,
@@ -42,8 +42,16 @@ type ErrCorruptedHook struct {
 }
 
 func (e *ErrCorruptedHook) Error() string {
-	return "Error: " + e.Name + " hook cannot be used. The file is corrupted or non-updatable. \n" +
-		"Please delete " + e.Path + " to resolve. \n" +
-		"If you need the currently installed hook, move it to " + e.Name + ".user, \n" +
-		"eg: mv " + e.Path + " " + e.Path + ".user"
+	return "Error: " + e.Name + " hook cannot be used. The file is not axi's or is non-updatable: " + e.Path + "\n" +
+		"Run `~/.axi/bin/axi doctor --fix` to keep it as " + e.Name + ".user, where it runs after axi, \n" +
+		"or move it yourself, eg: mv " + e.Path + " " + e.Path + ".user"
+}
+
+type ErrOutdatedHook struct {
+	Name string
+	Path string
+}
+
+func (e *ErrOutdatedHook) Error() string {
+	return e.Name + " hook is an older axi script: " + e.Path
 }
@@ -2,13 +2,16 @@ package hooks
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
 
+	"github.com/axilock/axi/internal/config"
 	"github.com/axilock/axi/internal/context"
 	"github.com/axilock/axi/internal/filesio"
 	"github.com/axilock/axi/internal/git"
@@ -19,7 +22,18 @@ const (
 )
 
 type Hook struct {
-	Name string
+	Name   string
+	Policy config.ChainPolicy // for hooks in <name>.d, stop on failure by default
+}
+
+// hooks git feeds input to on stdin, it is replayed to every hook of a chain
+var stdinHooks = map[string]bool{
+	"pre-push":              true,
+	"pre-receive":           true,
+	"post-receive":          true,
+	"post-rewrite":          true,
+	"reference-transaction": true,
+	"proc-receive":          true,
 }
 
 type HookError struct {
@@ -32,30 +46,23 @@ func (e *HookError) Error() string {
 	return fmt.Sprintf("Error while running hook %s. Exit code: %d. Reason: %s", e.Hook.Name, e.ExitCode, e.CausedBy.Error())
 }
 
+// HooksDir returns the absolute hooks dir git would use
 func HooksDir() (string, error) {
-	/*
-		// check local config
-		dir, err := git.GetLocalCoreHooksPath()
-		if err == nil && dir != "" {
-			return dir, nil
-		}
+	layout, err := git.ResolveLayout()
+	if err != nil {
+		return "", err
+	}
 
-		// check global config
-		dir, err = git.GetGlobalCoreHooksPath()
-		if err == nil {
-			return dir, nil
-		}
-	*/
 	dir, err := git.GetCoreHooksPath()
 	if err != nil {
 		return "", err
 	}
 	if dir != "" {
-		return dir, nil
+		return layout.Abs(dir), nil
 	}
 
 	// local & global configs don't exist, fallback to local hooks dir
-	return getLocalHooksDir()
+	return layout.HooksDir(), nil
 }
 
 func (h *Hook) Path() (string, error) {
@@ -84,13 +91,16 @@ func (h *Hook) Run(args ...string) *HookError {
 	if err != nil {
 		return h.Error(1, err)
 	}
+	return h.run(path, os.Stdin, args...)
+}
 
+func (h *Hook) run(path string, stdin io.Reader, args ...string) *HookError {
 	cmd := exec.Command(path, args...)
-	cmd.Stdin = os.Stdin
+	cmd.Stdin = stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
-	err = cmd.Run()
+	err := cmd.Run()
 	if err, ok := err.(*exec.ExitError); ok {
 		return h.Error(err.ExitCode(), err)
 	}
@@ -100,17 +110,74 @@ func (h *Hook) Run(args ...string) *HookError {
 	return nil
 }
 
+// Chain lists the hook, if it exists, followed by the executables
+// in <name>.d next to it, in lexical order.
+// Axi's wrapper scripts run <name>.d themselves, so it is not listed for them.
+func (h *Hook) Chain() ([]string, error) {
+	path, err := h.Path()
+	if err != nil {
+		return nil, err
+	}
+
+	var chain []string
+	if filesio.FileExists(path) {
+		chain = append(chain, path)
+		if content, err := os.ReadFile(path); err == nil && IsAnyAxiScript(string(content)) {
+			return chain, nil
+		}
+	}
+
+	// sorted by filename
+	entries, err := os.ReadDir(path + ".d")
+	if err != nil {
+		return chain, nil
+	}
+	for _, entry := range entries {
+		file := filepath.Join(path+".d", entry.Name())
+		info, err := os.Stat(file) // follow symlinks
+		if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+			continue
+		}
+		chain = append(chain, file)
+	}
+	return chain, nil
+}
+
+// RunIfExists runs the hook chain (see Chain) as per h.Policy.
+// The first failure is returned.
 func (h *Hook) RunIfExists(args ...string) *HookError {
 	var logger = context.Background().Logger()
 
-	if h.Exists() {
-		if err := h.Run(args...); err != nil {
-			logger.Error(err, "failed to run hook: "+h.Name)
-			return err
+	chain, err := h.Chain()
+	if err != nil {
+		return h.Error(1, err)
+	}
+
+	var input []byte
+	replay := len(chain) > 1 && stdinHooks[h.Name]
+	if replay {
+		if input, err = io.ReadAll(os.Stdin); err != nil {
+			return h.Error(1, err)
 		}
 	}
-	return nil
 
+	var first *HookError
+	for _, path := range chain {
+		var stdin io.Reader = os.Stdin
+		if replay {
+			stdin = bytes.NewReader(input)
+		}
+		if err := h.run(path, stdin, args...); err != nil {
+			logger.Error(err, "failed to run hook: "+path)
+			if first == nil {
+				first = err
+			}
+			if h.Policy != config.ChainRunAll {
+				break
+			}
+		}
+	}
+	return first
 }
 
 func MatchFile(filename, script string) error {
@@ -171,38 +238,25 @@ func UpdateHook(filename, header string) error {
 	return os.WriteFile(filename, []byte(newContent), 0755)
 }
 
-// Get local hooks dir: $GIT_DIR/hooks (absolute path)
+// Get local hooks dir: $GIT_COMMON_DIR/hooks (absolute path)
+// Linked worktrees share the hooks of the main repository
 func getLocalHooksDir() (string, error) {
-	git_dir, err := git.GitDir()
+	layout, err := git.ResolveLayout()
 	if err != nil {
 		return "", err
 	}
-
-	dir := filepath.Join(git_dir, "hooks")
-
-	absHooksDir, err := filepath.Abs(dir)
-	if err != nil {
-		return "", err
-	}
-
-	realAbsHooksDir, err := filepath.EvalSymlinks(absHooksDir)
-	if err != nil {
-		return "", err
-	}
-
-	return realAbsHooksDir, nil
+	return layout.HooksDir(), nil
 }
 
-// Get hooks dir relative to git config as current hooks dir might be set to
-// $SEKRIT_HOME/hook by global hooks or be empty
-func LocalHooksDirRelToGitTopLevel() (string, error) {
-	absHooksDir, err := getLocalHooksDir()
+// Get hooks dir as it should be set in local git config as current hooks dir
+// might be set to $AXI_HOME/hooks by global hooks or be empty
+func LocalHooksDirConfig() (string, error) {
+	layout, err := git.ResolveLayout()
 	if err != nil {
 		return "", err
 	}
 
-	return git.DirRelToGitTopLevel(absHooksDir)
-
+	return layout.HooksPathConfig(layout.HooksDir()), nil
 }
 
 func truncate(str string, length int) string {
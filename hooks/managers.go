@@ -0,0 +1,156 @@
+package hooks
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/axilock/axi/internal/context"
+	"github.com/axilock/axi/internal/filesio"
+	"github.com/axilock/axi/internal/git"
+)
+
+// HookManager is a third party tool owning a repo's git hooks.
+// husky sets core.hooksPath to its own dir, lefthook and pre-commit
+// write their scripts into the hooks dir git uses.
+type HookManager string
+
+const (
+	Husky     HookManager = "husky"
+	Lefthook  HookManager = "lefthook"
+	PreCommit HookManager = "pre-commit"
+)
+
+// files a manager keeps at the repo's top level
+var managerConfigs = map[HookManager][]string{
+	Husky: {".husky"},
+	Lefthook: {
+		"lefthook.yml", ".lefthook.yml", "lefthook.yaml", ".lefthook.yaml",
+		"lefthook.toml", ".lefthook.toml", "lefthook.json", ".lefthook.json",
+	},
+	PreCommit: {".pre-commit-config.yaml", ".pre-commit-config.yml"},
+}
+
+// DetectHookManagers lists the managers configured in the current worktree
+func DetectHookManagers(layout git.Layout) []HookManager {
+	if layout.TopLevel == "" {
+		return nil
+	}
+
+	var managers []HookManager
+	for _, manager := range []HookManager{Husky, Lefthook, PreCommit} {
+		for _, name := range managerConfigs[manager] {
+			if _, err := os.Stat(filepath.Join(layout.TopLevel, name)); err == nil {
+				managers = append(managers, manager)
+				break
+			}
+		}
+	}
+	return managers
+}
+
+// ScriptHookManager returns the manager which generated a hook script,
+// empty if none did
+func ScriptHookManager(script string) HookManager {
+	switch {
+	case IsAnyAxiScript(script):
+		return ""
+	case strings.Contains(script, "husky"):
+		return Husky
+	case strings.Contains(script, "lefthook"):
+		return Lefthook
+	case strings.Contains(script, "generated by pre-commit"),
+		strings.Contains(script, "pre-commit.com"):
+		return PreCommit
+	}
+	return ""
+}
+
+// HooksDirManager returns the manager owning hooks dir, empty if none does
+func HooksDirManager(dir string) HookManager {
+	// husky v5+ uses .husky, v9 uses .husky/_
+	if base := filepath.Base(dir); base == ".husky" ||
+		(base == "_" && filepath.Base(filepath.Dir(dir)) == ".husky") {
+		return Husky
+	}
+
+	for _, name := range git.HookNames {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		if manager := ScriptHookManager(string(content)); manager != "" {
+			return manager
+		}
+	}
+	return ""
+}
+
+// chainHookManager keeps the hooks of a manager which set the local
+// core.hooksPath to its own dir working once axi switches it back to the
+// local hooks dir: the configured value is recorded in axi.chainHooksPath,
+// where the pre-push wrapper script looks for the manager's pre-push hook,
+// and other hooks of the manager get a forwarder in the local hooks dir.
+func chainHookManager(layout git.Layout, configured string, manager HookManager) error {
+	var logger = context.Background().Logger()
+
+	if err := git.SetLocalChainHooksPath(configured); err != nil {
+		return err
+	}
+	logger.Info(fmt.Sprintf("Chaining %s hooks from %s", manager, configured))
+
+	dir := layout.Abs(configured)
+	localHooksDir := layout.HooksDir()
+	for _, name := range git.HookNames {
+		if name == "pre-push" {
+			continue // run by the pre-push wrapper, after axi's scan
+		}
+		target := filepath.Join(dir, name)
+		if info, err := os.Stat(target); err != nil || info.Mode().Perm()&0111 == 0 {
+			continue
+		}
+		if err := createOrUpdateForwarderHook(name, localHooksDir, target); err != nil {
+			logger.Error(err, "Could not chain "+string(manager)+" hook: "+name)
+		}
+	}
+	return nil
+}
+
+// createOrUpdateForwarderHook writes a hook running target in place.
+// Hooks not owned by axi are left untouched.
+func createOrUpdateForwarderHook(name, hooksDir, target string) error {
+	script := NewAxiShellScript(fmt.Sprintf(`
+CHAINED_HOOK="%s"
+if [ -x "$CHAINED_HOOK" ]; then
+	exec "$CHAINED_HOOK" "$@"
+fi`, target))
+
+	path := filepath.Join(hooksDir, name)
+	if filesio.FileExists(path) {
+		existing, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if script.Match(string(existing)) {
+			return nil
+		}
+		if !IsAnyAxiScript(string(existing)) {
+			return &ErrCorruptedHook{Name: name, Path: path}
+		}
+	}
+	return filesio.WriteExecutableFileWithContent(path, script.String())
+}
+
+// managerReplacedHook returns the axi script a manager moved aside when it
+// installed its own hook at path (<name>.old for lefthook, <name>.legacy
+// for pre-commit). The manager keeps running it, so axi still runs.
+func managerReplacedHook(path string) string {
+	for _, suffix := range []string{".legacy", ".old"} {
+		content, err := os.ReadFile(path + suffix)
+		if err == nil && IsAnyAxiScript(string(content)) {
+			return path + suffix
+		}
+	}
+	return ""
+}
@@ -2,18 +2,27 @@ package hooks
 
 import (
 	"bufio"
+	"errors"
+	"fmt"
 	"os"
+	"path"
+	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/axilock/axi/internal/config"
 	"github.com/axilock/axi/internal/context"
 	"github.com/axilock/axi/internal/git"
+	"github.com/axilock/axi/internal/registry"
 	"github.com/axilock/axi/scanner"
 )
 
 type PrePushHookOutput struct {
-	Commits []git.Commit
-	Secrets []scanner.Secret
-	message string
+	Commits    []git.Commit
+	Secrets    []scanner.Secret
+	Warnings   []string // parts of the push that could not be scanned
+	ScanErrors []error  // scanner failures and timeouts
+	message    string
 }
 
 func (p *PrePushHookOutput) Message() string {
@@ -31,10 +40,44 @@ func (p *PrePushHookOutput) Message() string {
 	return msg
 }
 
+// CoverageMessage explains which parts of the push were not scanned,
+// empty if the scan was complete
+func (p *PrePushHookOutput) CoverageMessage() string {
+	if len(p.Warnings) == 0 {
+		return ""
+	}
+	msg := "[!] Axilock scanned this push partially:\n"
+	for _, warning := range p.Warnings {
+		msg += "    - " + warning + "\n"
+	}
+	return msg
+}
+
+// ScanFailureMessage explains why the scan failed and whether the push
+// was blocked because of it
+func (p *PrePushHookOutput) ScanFailureMessage(blocked bool) string {
+	if len(p.ScanErrors) == 0 {
+		return ""
+	}
+	msg := "================ AXILOCK PUSH PROTECTION ================\n"
+	if blocked {
+		msg += "    Secret scan failed, push blocked as per policy (on_scan_failure: closed).\n"
+	} else {
+		msg += "    Secret scan failed, push allowed without a complete scan.\n"
+	}
+	for _, err := range p.ScanErrors {
+		msg += "    - " + err.Error() + "\n"
+	}
+	return msg
+}
+
 type PrePushHook struct {
-	name    string
-	home    string
-	scanner scanner.SecretScanner
+	name           string
+	home           string
+	scanner        scanner.SecretScanner
+	scanSubmodules bool
+	scanTimeout    time.Duration
+	chainPolicy    config.ChainPolicy
 }
 
 func NewPrePushHook(home string, scanner scanner.SecretScanner) PrePushHook {
@@ -42,17 +85,68 @@ func NewPrePushHook(home string, scanner scanner.SecretScanner) PrePushHook {
 	return hook
 }
 
+// WithScanTimeout stops scanning after timeout, 0 means no limit
+func (p PrePushHook) WithScanTimeout(timeout time.Duration) PrePushHook {
+	p.scanTimeout = timeout
+	return p
+}
+
+// WithSubmoduleScan also scans submodule commits newly referenced by the push
+func (p PrePushHook) WithSubmoduleScan(enabled bool) PrePushHook {
+	p.scanSubmodules = enabled
+	return p
+}
+
+// WithChainPolicy sets the policy the pre-push wrapper script applies
+// to pre-push.user and pre-push.d when it is regenerated
+func (p PrePushHook) WithChainPolicy(policy config.ChainPolicy) PrePushHook {
+	p.chainPolicy = policy
+	return p
+}
+
 func (p *PrePushHook) Run(remote, url string) (out PrePushHookOutput, err error) {
 	var logger = context.Background().Logger()
 
-	if err := ensureUpdatedPrePushHook(p.home); err != nil {
+	if err := ensureUpdatedPrePushHook(p.home, p.chainPolicy); err != nil {
 		logger.Error(err, "Could not validate existing pre push hook")
 	}
 
 	logger.Info("Running pre-push hook on " + remote + " " + url)
+	layout, err := git.ResolveLayout()
+	if err != nil {
+		return PrePushHookOutput{}, err
+	}
+	logger.V(1).Info("Scanning from " + layout.RunDir())
+
+	reader := git.NewObjectReader("")
+	defer reader.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if p.scanTimeout > 0 {
+		ctx, cancel = context.WithTimeoutCause(context.Background(), p.scanTimeout,
+			&scanner.ErrScanTimeout{Timeout: p.scanTimeout})
+	}
+	defer cancel()
+
+	var shallow map[string]bool
+	if layout.Shallow {
+		shallow = layout.ShallowCommits()
+	}
+
 	bufScanner := bufio.NewScanner(os.Stdin)
 	var allCommits []git.Commit // across all branches being pushed
 	var allSecrets []scanner.Secret
+	var warnings []string
+	var scanErrors []error
+	recordScanError := func(err error) {
+		var partial *scanner.PartialScanError
+		if errors.As(err, &partial) {
+			warnings = append(warnings, partial.Error())
+		} else if err != nil {
+			logger.Error(err, "Error running scanner")
+			scanErrors = append(scanErrors, err)
+		}
+	}
 	for bufScanner.Scan() {
 		var since, branch string
 		line := bufScanner.Text()
@@ -76,7 +170,7 @@ func (p *PrePushHook) Run(remote, url string) (out PrePushHookOutput, err error)
 		if !git.IsZeroHash(remoteOID) {
 			// since = remoteOID //FIXME: This could be wrong, we need to find the common ancestor
 			// That's why we reuse LastPushedCommitReachableByBranch
-			since, _ = git.LastPushedCommitReachableByBranch(branch)
+			since, _ = reader.LastPushedCommit(branch)
 			logger.V(1).Info("Last pushed commit reachable by branch is: " + since)
 		} else {
 			// new branch
@@ -85,33 +179,112 @@ func (p *PrePushHook) Run(remote, url string) (out PrePushHookOutput, err error)
 			// Not really, it is possible only one branch is being pushed and other parent branches are not.
 			// in this case, the current branch will have all commits of local parent branches as well
 			// and needs to be scanned
-			since, _ = git.LastPushedCommitReachableByBranch(branch)
+			since, _ = reader.LastPushedCommit(branch)
 			logger.V(1).Info("Last pushed commit reachable by branch is: " + since)
 		}
 
-		commits := git.GetCommitsList(since, branch)
+		commits, err := reader.Commits(since, branch)
+		if err != nil {
+			logger.Error(err, "Could not list commits")
+		}
 
 		if err := bufScanner.Err(); err != nil {
 			return PrePushHookOutput{Commits: commits}, err
 		}
 
-		dir := os.Getenv("GIT_DIR")
-		secrets, err := p.scanner.Run(dir, since, branch)
-		if err != nil {
-			logger.Error(err, "Error running scanner")
+		for _, commit := range commits {
+			if shallow[commit.ID] {
+				warnings = append(warnings, "Shallow clone, history before "+commit.ID+
+					" is not available. Its whole content was scanned as new")
+			}
 		}
 
+		secrets, err := p.scanner.Run(ctx, layout.RunDir(), since, branch)
+		recordScanError(err)
+
 		allCommits = append(allCommits, commits...)
 		allSecrets = append(allSecrets, secrets...)
+
+		if p.scanSubmodules {
+			secrets, errs := p.scanSubmoduleUpdates(ctx, reader, layout, since, branch)
+			allSecrets = append(allSecrets, secrets...)
+			for _, err := range errs {
+				recordScanError(err)
+			}
+		}
+	}
+
+	if err := registry.New(p.home).RecordScan(layout, url, time.Now()); err != nil {
+		logger.Error(err, "Could not record scan in registry")
 	}
 
-	return PrePushHookOutput{Commits: allCommits, Secrets: allSecrets}, nil
+	return PrePushHookOutput{
+		Commits:    allCommits,
+		Secrets:    allSecrets,
+		Warnings:   warnings,
+		ScanErrors: scanErrors,
+	}, nil
+}
+
+// UpdatePrePushHook installs or updates axi's pre-push script in the
+// current repo's hooks dir. Hooks axi does not own are not replaced.
+func UpdatePrePushHook(home string, policy config.ChainPolicy) error {
+	return ensureUpdatedPrePushHook(home, policy)
 }
 
-func ensureUpdatedPrePushHook(home string) error {
-	return ensureUpdatedAxiHook(home, "pre-push")
+func ensureUpdatedPrePushHook(home string, policy config.ChainPolicy) error {
+	return ensureUpdatedAxiHook(home, "pre-push", policy)
 }
 
-func createOrUpdatePrePushHook(home, localHooksDir string) error {
-	return createOrUpdateAxiHook(home, "pre-push", localHooksDir)
+func createOrUpdatePrePushHook(home, localHooksDir string, policy config.ChainPolicy) error {
+	return createOrUpdateAxiHook(home, "pre-push", localHooksDir, policy)
+}
+
+// scanSubmoduleUpdates scans the submodule commits referenced by branch
+// but not by since. Submodules which are not checked out, or do not have
+// the referenced commit locally, are skipped.
+func (p *PrePushHook) scanSubmoduleUpdates(ctx context.Context, reader *git.ObjectReader, layout git.Layout, since, branch string) ([]scanner.Secret, []error) {
+	var logger = context.Background().Logger()
+
+	updates, err := reader.SubmoduleUpdates(since, branch)
+	if err != nil {
+		logger.Error(err, "Could not list submodule updates")
+		return nil, []error{err}
+	}
+
+	if layout.TopLevel == "" {
+		logger.Info("Skipping submodules, no working tree")
+		return nil, nil
+	}
+
+	var secrets []scanner.Secret
+	var errs []error
+	for _, update := range updates {
+		dir := filepath.Join(layout.TopLevel, filepath.FromSlash(update.Path))
+		subReader := git.NewObjectReader(dir)
+
+		if _, err := subReader.Info(update.To + "^{commit}"); err != nil {
+			logger.Info("Skipping submodule " + update.Path + ", commit " + update.To + " not available locally")
+			subReader.Close()
+			continue
+		}
+
+		since := update.From
+		if since == "" {
+			// newly added submodule, commits present on its remotes were scanned when pushed
+			since, _ = subReader.LastPushedCommit(update.To)
+		}
+		subReader.Close()
+
+		logger.Info("Scanning submodule " + update.Path + " " + since + ".." + update.To)
+		found, err := p.scanner.Run(ctx, dir, since, update.To)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("submodule %s: %w", update.Path, err))
+		}
+		for _, secret := range found {
+			secret.File = path.Join(update.Path, secret.File)
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets, errs
 }
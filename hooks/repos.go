@@ -0,0 +1,102 @@
+package hooks
+
+import (
+	"errors"
+	"os"
+
+	"github.com/axilock/axi/internal/config"
+	"github.com/axilock/axi/internal/git"
+	"github.com/axilock/axi/internal/registry"
+)
+
+// RepoStatus is the state of axi's hooks in a repo
+type RepoStatus string
+
+const (
+	RepoOK RepoStatus = "ok"
+	// pre-push script outdated or missing, or core.hooksPath changed
+	RepoDrifted RepoStatus = "drifted"
+	// pre-push hook replaced by a script axi does not own
+	RepoCorrupted RepoStatus = "corrupted"
+	RepoDisabled  RepoStatus = "disabled"
+)
+
+// CheckRepo returns the status of axi's hooks in the current repo
+func CheckRepo(home string, policy config.ChainPolicy) (RepoStatus, error) {
+	layout, err := git.ResolveLayout()
+	if err != nil {
+		return "", err
+	}
+
+	disabled, err := git.GetLocalDisabled()
+	if err != nil {
+		return "", err
+	}
+	if disabled {
+		return RepoDisabled, nil
+	}
+
+	var outdated *ErrOutdatedHook
+	var corrupted *ErrCorruptedHook
+	err = CheckAxiHook(home, "pre-push", layout.HooksDir(), policy)
+	switch {
+	case errors.As(err, &corrupted):
+		return RepoCorrupted, nil
+	case errors.As(err, &outdated), errors.Is(err, os.ErrNotExist):
+		return RepoDrifted, nil
+	case err != nil:
+		return "", err
+	}
+
+	local, err := git.GetLocalCoreHooksPath()
+	if err != nil {
+		return "", err
+	}
+	if !layout.IsHooksDir(local, layout.HooksDir()) {
+		return RepoDrifted, nil
+	}
+	return RepoOK, nil
+}
+
+// DisableRepo turns axi's protection off in the current repo: its hooks
+// are reverted as on uninstall, and axi's global hooks no longer set it
+// up. Every change is passed to report.
+func DisableRepo(home string, report func(change string)) (registry.Repo, error) {
+	layout, err := git.ResolveLayout()
+	if err != nil {
+		return registry.Repo{}, err
+	}
+
+	var errs []error
+	change := func(description string, apply func() error) {
+		report(description)
+		if err := apply(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := revertHooks(layout, change, report); err != nil {
+		return registry.Repo{}, err
+	}
+	change("Set axi.disabled", git.SetLocalDisabled)
+	if err := errors.Join(errs...); err != nil {
+		return registry.Repo{}, err
+	}
+	return registry.New(home).SetDisabled(layout, git.PushRepoURL(), true)
+}
+
+// EnableRepo turns axi's protection back on in the current repo and
+// sets it up right away
+func EnableRepo(home string, policy config.ChainPolicy) (registry.Repo, error) {
+	if err := git.UnsetLocalDisabled(); err != nil {
+		return registry.Repo{}, err
+	}
+	if err := SetupRepo(home, policy); err != nil {
+		return registry.Repo{}, err
+	}
+
+	layout, err := git.ResolveLayout()
+	if err != nil {
+		return registry.Repo{}, err
+	}
+	return registry.New(home).SetDisabled(layout, git.PushRepoURL(), false)
+}
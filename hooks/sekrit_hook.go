@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/axilock/axi/internal/config"
 	"github.com/axilock/axi/internal/context"
 	"github.com/axilock/axi/internal/filesio"
 )
@@ -75,12 +76,12 @@ func IsOldAxiScript(script string) bool {
 	return false
 }
 
-func ensureUpdatedAxiHook(home, name string) error {
+func ensureUpdatedAxiHook(home, name string, policy config.ChainPolicy) error {
 	localHooksDir, err := getLocalHooksDir()
 	if err != nil {
 		return err
 	}
-	return createOrUpdateAxiHook(home, name, localHooksDir)
+	return createOrUpdateAxiHook(home, name, localHooksDir, policy)
 }
 
 // createOrUpdateAxiHook creates or updates the hook name given.
@@ -90,36 +91,10 @@ func ensureUpdatedAxiHook(home, name string) error {
 // as per 'script' parameter.
 // In order to update header/footer itself, add old ones in
 // IsOldAxiScript and modify NewAxiShellScript struct
-func createOrUpdateAxiHook(home, name, hooksDir string) error {
+func createOrUpdateAxiHook(home, name, hooksDir string, policy config.ChainPolicy) error {
 	var logger = context.Background().Logger()
 
-	afs := filesio.AxiFS{Home: home}
-	//FIXME: .git/hooks/pre-push.user will not work for submodules
-	script := NewAxiShellScript(fmt.Sprintf(`
-AXI="%s"
-HOOK_NAME=$(basename "$0")
-HOOK="$0"
-USER_HOOK=".git/hooks/$HOOK_NAME.user"
-INPUT=$(cat)
-if [ -f "$AXI" ]; then
-    printf "%%s" "$INPUT" | "$AXI" hook "$HOOK_NAME" "$@"
-    RET=$?
-    if [ $RET -ne 0 ]; then
-        exit $RET
-    fi
-elif [ -f "$USER_HOOK" ]; then
-	git config --local --unset core.hooksPath
-	mv "$USER_HOOK" "$HOOK"
-else
-	git config --local --unset core.hooksPath
-	rm "$HOOK"
-fi
-
-# user defined hook
-if [ -f "$USER_HOOK" ]; then
-	printf "%%s" "$INPUT" | "$USER_HOOK" "$@"
-    exit $?
-fi`, afs.BinaryPath()))
+	script := axiHookScript(home, policy)
 
 	path := filepath.Join(hooksDir, name)
 	// Happy flow: no user defined hook exists
@@ -148,6 +123,31 @@ fi`, afs.BinaryPath()))
 		return nil
 	}
 
+	// Hooks generated by a manager (lefthook, pre-commit, husky v4)
+	if manager := ScriptHookManager(existing); manager != "" {
+		// manager replaced this script, kept it aside and runs it
+		if replaced := managerReplacedHook(path); replaced != "" {
+			content, err := os.ReadFile(replaced)
+			if err != nil {
+				return err
+			}
+			if script.Match(string(content)) {
+				return nil
+			}
+			logger.Info("Updating axi script kept by " + string(manager) + ": " + replaced)
+			return filesio.WriteExecutableFileWithContent(replaced, script.String())
+		}
+
+		// run manager's hook after axi's scan
+		if userHook := path + ".user"; !filesio.FileExists(userHook) {
+			logger.Info("Moving " + string(manager) + " hook to " + userHook)
+			if err := os.Rename(path, userHook); err != nil {
+				return err
+			}
+			return filesio.WriteExecutableFileWithContent(path, script.String())
+		}
+	}
+
 	// Unhappy flow: user's hook is not axi owned
 	if err := MatchFile(path, script.String()); err != nil {
 		err = &ErrCorruptedHook{Name: name, Path: path, MatchError: err}
@@ -157,6 +157,144 @@ fi`, afs.BinaryPath()))
 	return nil
 }
 
+// axiHookScript is the wrapper script running axi, then user hooks
+func axiHookScript(home string, policy config.ChainPolicy) *AxiShellScript {
+	if policy != config.ChainRunAll {
+		policy = config.ChainStopOnFailure
+	}
+
+	afs := filesio.AxiFS{Home: home}
+	// user hooks live next to this script, which is in $GIT_DIR/hooks
+	// for repos as well as submodules (.git/modules/<name>/hooks).
+	// <name>.user runs first, then executables in <name>.d in lexical order,
+	// then the hook of a chained hook manager (see chainHookManager),
+	// each one reading the same stdin.
+	// Hook managers keep this script as <name>.old or <name>.legacy
+	// when they install their own hook, and run it from there.
+	return NewAxiShellScript(fmt.Sprintf(`
+AXI="%s"
+CHAIN_POLICY="%s"
+HOOK_NAME=$(basename "$0")
+HOOK_NAME=${HOOK_NAME%%.old}
+HOOK_NAME=${HOOK_NAME%%.legacy}
+HOOK="$0"
+CHAIN_HOOKS_PATH=$(git config --local axi.chainHooksPath)
+USER_HOOK="$(dirname "$0")/$HOOK_NAME.user"
+USER_HOOKS_D="$(dirname "$0")/$HOOK_NAME.d"
+INPUT=$(cat)
+if [ -f "$AXI" ]; then
+    printf "%%s" "$INPUT" | "$AXI" hook "$HOOK_NAME" "$@"
+    RET=$?
+    if [ $RET -ne 0 ]; then
+        exit $RET
+    fi
+elif [ -n "$CHAIN_HOOKS_PATH" ]; then
+	git config --local core.hooksPath "$CHAIN_HOOKS_PATH"
+	git config --local --unset axi.chainHooksPath
+elif [ -f "$USER_HOOK" ]; then
+	git config --local --unset core.hooksPath
+	mv "$USER_HOOK" "$HOOK"
+else
+	git config --local --unset core.hooksPath
+	rm "$HOOK"
+fi
+
+RET=0
+# user defined hook
+if [ -f "$USER_HOOK" ]; then
+	printf "%%s" "$INPUT" | "$USER_HOOK" "$@"
+	RET=$?
+	if [ $RET -ne 0 ] && [ "$CHAIN_POLICY" != "run-all" ]; then
+		exit $RET
+	fi
+fi
+
+# user defined hooks.d
+if [ -d "$USER_HOOKS_D" ]; then
+	USER_HOOKS=$(LC_ALL=C ls -1 "$USER_HOOKS_D")
+	while IFS= read -r NAME; do
+		FILE="$USER_HOOKS_D/$NAME"
+		if [ -z "$NAME" ] || [ -d "$FILE" ] || [ ! -x "$FILE" ]; then
+			continue
+		fi
+		printf "%%s" "$INPUT" | "$FILE" "$@"
+		CODE=$?
+		if [ $CODE -ne 0 ]; then
+			if [ $RET -eq 0 ]; then
+				RET=$CODE
+			fi
+			if [ "$CHAIN_POLICY" != "run-all" ]; then
+				exit $RET
+			fi
+		fi
+	done <<EOF
+$USER_HOOKS
+EOF
+fi
+
+# hook manager's hook
+if [ -n "$CHAIN_HOOKS_PATH" ] && [ -x "$CHAIN_HOOKS_PATH/$HOOK_NAME" ]; then
+	printf "%%s" "$INPUT" | "$CHAIN_HOOKS_PATH/$HOOK_NAME" "$@"
+	CODE=$?
+	if [ $RET -eq 0 ]; then
+		RET=$CODE
+	fi
+fi
+exit $RET`, afs.BinaryPath(), policy))
+}
+
+// CheckAxiHook verifies the hook at hooksDir/name is axi's current script.
+// Returns an error wrapping os.ErrNotExist if there is no hook,
+// ErrOutdatedHook for older axi scripts (updated on the next push)
+// and ErrCorruptedHook for scripts axi does not own.
+func CheckAxiHook(home, name, hooksDir string, policy config.ChainPolicy) error {
+	path := filepath.Join(hooksDir, name)
+	existing, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	script := axiHookScript(home, policy)
+	if script.Match(string(existing)) {
+		return nil
+	}
+	if IsAnyAxiScript(string(existing)) {
+		return &ErrOutdatedHook{Name: name, Path: path}
+	}
+	if ScriptHookManager(string(existing)) != "" {
+		if replaced := managerReplacedHook(path); replaced != "" {
+			content, err := os.ReadFile(replaced)
+			if err != nil {
+				return err
+			}
+			if !script.Match(string(content)) {
+				return &ErrOutdatedHook{Name: name, Path: replaced}
+			}
+			return nil
+		}
+	}
+	return &ErrCorruptedHook{Name: name, Path: path, MatchError: MatchFile(path, script.String())}
+}
+
+// PreserveUserHook moves a hook axi does not own out of axi's way, to
+// <name>.user or, if taken, into <name>.d, where axi's script runs it.
+// Returns the new path.
+func PreserveUserHook(path string) (string, error) {
+	target := path + ".user"
+	if filesio.FileExists(target) {
+		dir := path + ".d"
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", err
+		}
+		name := filepath.Base(path)
+		target = filepath.Join(dir, name)
+		for i := 1; filesio.FileExists(target); i++ {
+			target = filepath.Join(dir, fmt.Sprintf("%s-%d", name, i))
+		}
+	}
+	return target, os.Rename(path, target)
+}
+
 /*
 type AxiHook struct {
 	Name string
@@ -0,0 +1,99 @@
+package hooks
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/axilock/axi/internal/filesio"
+	"github.com/axilock/axi/internal/git"
+)
+
+// RevertRepo undoes what axi did in the current repo: removes axi's
+// scripts, moves <hook>.user files back in their place and restores the
+// local config. Every change is passed to report, and only reported
+// if dryRun is set.
+func RevertRepo(dryRun bool, report func(change string)) error {
+	layout, err := git.ResolveLayout()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	change := func(description string, apply func() error) {
+		report(description)
+		if dryRun {
+			return
+		}
+		if err := apply(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := revertHooks(layout, change, report); err != nil {
+		return err
+	}
+	if id, _ := git.GetLocalRepoID(); id != "" {
+		change("Unset axi.repoId", git.UnsetLocalRepoID)
+	}
+	if disabled, _ := git.GetLocalDisabled(); disabled {
+		change("Unset axi.disabled", git.UnsetLocalDisabled)
+	}
+	return errors.Join(errs...)
+}
+
+// revertHooks removes axi's scripts from the repo's hooks dir, puts
+// user hooks back and restores the local core.hooksPath
+func revertHooks(layout git.Layout, change func(string, func() error), report func(string)) error {
+	hooksDir := layout.HooksDir()
+	entries, err := os.ReadDir(hooksDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	removed := make(map[string]bool)
+	for _, entry := range entries {
+		path := filepath.Join(hooksDir, entry.Name())
+		content, err := os.ReadFile(path)
+		if entry.IsDir() || err != nil || !IsAnyAxiScript(string(content)) {
+			continue
+		}
+		change("Remove axi script "+path, func() error { return os.Remove(path) })
+		removed[entry.Name()] = true
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(hooksDir, entry.Name())
+		if name, ok := strings.CutSuffix(entry.Name(), ".d"); ok && entry.IsDir() {
+			report("Keep " + path + ", git does not run " + name + ".d hooks without axi")
+			continue
+		}
+		name, ok := strings.CutSuffix(entry.Name(), ".user")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		target := filepath.Join(hooksDir, name)
+		if filesio.FileExists(target) && !removed[name] {
+			report("Keep " + path + ", " + target + " exists")
+			continue
+		}
+		change("Move "+path+" back to "+target, func() error { return os.Rename(path, target) })
+	}
+
+	local, err := git.GetLocalCoreHooksPath()
+	if err != nil {
+		return err
+	}
+	chained, err := git.GetLocalChainHooksPath()
+	if err != nil {
+		return err
+	}
+	if chained != "" {
+		change("Set core.hooksPath back to "+chained, func() error { return git.SetLocalCoreHooksPath(chained) })
+		change("Unset axi.chainHooksPath", git.UnsetLocalChainHooksPath)
+	} else if local != "" && layout.IsHooksDir(local, hooksDir) {
+		change("Unset core.hooksPath", git.UnsetLocalCoreHooksPath)
+	}
+	return nil
+}
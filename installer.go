@@ -8,7 +8,6 @@ import (
 	"github.com/axilock/axi/internal/config"
 	"github.com/axilock/axi/internal/context"
 	"github.com/axilock/axi/internal/filesio"
-	"github.com/axilock/axi/internal/git"
 	"github.com/axilock/axi/internal/utils"
 	pb "github.com/axilock/axilock-protos/client"
 	"github.com/go-logr/logr"
@@ -28,7 +27,9 @@ type ReInstallCmd struct {
 	SkipDependencies bool `help:"Skip dependencies" default:"false"`
 }
 
-type UninstallCmd struct{}
+type UninstallCmd struct {
+	DryRun bool `help:"Print what uninstall would change, without changing it" default:"false"`
+}
 
 func (c *InstallCmd) Run(cfg *config.Config, logger logr.Logger) error {
 	conn, err := cfg.FreshGRPCConn()
@@ -121,15 +122,19 @@ func (c *ReInstallCmd) Run(cli *CLI, cfg *config.Config, logger logr.Logger, con
 
 func (r *UninstallCmd) Run(cfg *config.Config, conn *grpc.ClientConn) error {
 	// TODO: Send logs to backend about uninstall
-	if err := git.UnsetGlobalCoreHooksPath(); err != nil {
-		return err
+	prefix := ""
+	if r.DryRun {
+		prefix = "Would: "
 	}
-
-	afs := filesio.AxiFS{Home: cfg.Home()}
-	if err := afs.Delete(); err != nil {
+	err := installer.Uninstall(cfg.Home(), r.DryRun, func(change string) {
+		fmt.Println(prefix + change)
+	})
+	if err != nil {
 		return err
 	}
-	fmt.Println("Uninstall successfull")
+	if !r.DryRun {
+		fmt.Println("Uninstall successfull")
+	}
 	return nil
 }
 
@@ -12,6 +12,9 @@ import (
 	"github.com/axilock/axi/internal/filesio"
 )
 
+// TrufflehogVersion is the trufflehog release axi installs
+const TrufflehogVersion = "3.89.1"
+
 // InstallTrufflehog downloads and installs trufflehog in the axi directory
 func InstallTrufflehog(home string) error {
 	goos := runtime.GOOS
@@ -20,7 +23,7 @@ func InstallTrufflehog(home string) error {
 	afs := filesio.AxiFS{Home: home}
 	binDir := afs.BinaryDir()
 
-	version := "3.89.1"
+	version := TrufflehogVersion
 	var url string
 
 	switch goos {
@@ -9,31 +9,6 @@ import (
 	"github.com/axilock/axi/internal/git"
 )
 
-var hooks []string = []string{
-	"applypatch-msg",
-	"commit-msg",
-	"fsmonitor-watchman",
-	"post-applypatch",
-	"post-checkout",
-	"post-commit",
-	"post-merge",
-	"post-receive",
-	"post-rewrite",
-	"post-update",
-	"pre-applypatch",
-	"pre-auto-gc",
-	"pre-commit",
-	"pre-merge-commit",
-	"pre-push",
-	"pre-rebase",
-	"pre-receive",
-	"prepare-commit-msg",
-	"push-to-checkout",
-	"reference-transaction",
-	"sendemail-validate",
-	"update",
-}
-
 func Install(home, apiKey string) error {
 	var logger = context.Background().Logger()
 
@@ -63,7 +38,7 @@ func Install(home, apiKey string) error {
 	}
 
 	// Currently all hooks are symlinks to axi binary itself
-	for _, hook := range hooks {
+	for _, hook := range git.HookNames {
 		if err := installHook(hook, afs.HooksDir(), afs.BinaryPath()); err != nil {
 			logger.Error(err, "Error writing hook:"+hook)
 			return err
@@ -103,6 +78,45 @@ func assertEmptyOrAxiGlobalCoreHooksDir(expectedPath string) error {
 	return nil
 }
 
+// RepairHooks restores axi's binary from the running executable if it is
+// missing, and the hook symlinks in axi's hooks dir, replacing wrong ones
+func RepairHooks(home string) error {
+	var logger = context.Background().Logger()
+
+	afs := filesio.AxiFS{Home: home}
+	if !filesio.FileExists(afs.BinaryPath()) {
+		current, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(afs.BinaryDir(), 0755); err != nil {
+			return err
+		}
+		if err := filesio.CopyBinary(current, afs.BinaryPath()); err != nil {
+			return err
+		}
+		logger.Info("Binary restored: " + afs.BinaryPath())
+	}
+
+	if err := os.MkdirAll(afs.HooksDir(), 0755); err != nil {
+		return err
+	}
+	for _, hook := range git.HookNames {
+		path := filepath.Join(afs.HooksDir(), hook)
+		if target, err := os.Readlink(path); err == nil && filepath.Clean(target) == afs.BinaryPath() {
+			continue
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+		if err := installHook(hook, afs.HooksDir(), afs.BinaryPath()); err != nil {
+			return err
+		}
+		logger.Info("Hook relinked: " + hook)
+	}
+	return nil
+}
+
 func installHook(name, dir, hookBinary string) error {
 	return os.Symlink(hookBinary, filepath.Join(dir, name))
 }
@@ -0,0 +1,81 @@
+package installer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/axilock/axi/hooks"
+	"github.com/axilock/axi/internal/filesio"
+	"github.com/axilock/axi/internal/git"
+	"github.com/axilock/axi/internal/registry"
+)
+
+// Uninstall reverts axi's changes to the enrolled repos and to the global
+// git config, then deletes axi's home. Every change is passed to report,
+// and only reported if dryRun is set. Repos which can't be reverted don't
+// stop the uninstall, their errors are returned at the end.
+func Uninstall(home string, dryRun bool, report func(change string)) error {
+	afs := filesio.AxiFS{Home: home}
+
+	var errs []error
+	change := func(description string, apply func() error) {
+		report(description)
+		if dryRun {
+			return
+		}
+		if err := apply(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	repos, err := registry.New(home).List()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("could not read enrolled repos: %w", err))
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	for _, repo := range repos {
+		if !repo.Exists() {
+			report("Skip " + repo.Path + ", repo not found")
+			continue
+		}
+		if err := os.Chdir(repo.Path); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := hooks.RevertRepo(dryRun, report); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", repo.Path, err))
+		}
+	}
+	if err := os.Chdir(cwd); err != nil {
+		return err
+	}
+
+	global, err := git.GetGlobalCoreHooksPath()
+	if err != nil {
+		return err
+	}
+	previous, err := git.GetGlobalPreviousHooksPath()
+	if err != nil {
+		return err
+	}
+	if global == afs.HooksDir() {
+		if previous != "" {
+			change("Set global core.hooksPath back to "+previous, func() error {
+				return git.SetGlobalCoreHooksPath(previous)
+			})
+		} else {
+			change("Unset global core.hooksPath", git.UnsetGlobalCoreHooksPath)
+		}
+	}
+	if previous != "" {
+		change("Unset global axi.previousHooksPath", git.UnsetGlobalPreviousHooksPath)
+	}
+
+	change("Delete "+home, afs.Delete)
+	return errors.Join(errs...)
+}
@@ -3,9 +3,11 @@ package config
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/getsentry/sentry-go"
 	"github.com/goccy/go-yaml"
@@ -36,6 +38,37 @@ const (
 	Rel Env = "release"
 )
 
+type ScanMode string
+
+const (
+	// ScanHistory reports a secret in every pushed commit touching it
+	ScanHistory ScanMode = "history"
+	// ScanDiff reports a secret only in the commit adding it
+	ScanDiff ScanMode = "diff"
+)
+
+// FailurePolicy decides what happens to a push when the
+// scan times out or the scanner fails
+type FailurePolicy string
+
+const (
+	// FailOpen lets the push through with a warning
+	FailOpen FailurePolicy = "open"
+	// FailClosed blocks the push
+	FailClosed FailurePolicy = "closed"
+)
+
+// ChainPolicy decides whether the remaining user hooks of a chain
+// (<hook>.user, then <hook>.d/*) run after one of them fails
+type ChainPolicy string
+
+const (
+	// ChainStopOnFailure skips the remaining hooks, failing with the first error
+	ChainStopOnFailure ChainPolicy = "stop-on-failure"
+	// ChainRunAll runs every hook, failing with the first error
+	ChainRunAll ChainPolicy = "run-all"
+)
+
 type Config struct {
 	AxiHomeDirName           string
 	Debug                    bool
@@ -50,6 +83,12 @@ type Config struct {
 	Verbose                  bool
 	FrontendURL              string
 	Offline                  bool
+	ScanMode                 ScanMode
+	DiffFindRenames          bool
+	ScanSubmodules           bool
+	ScanTimeout              time.Duration
+	OnScanFailure            FailurePolicy
+	HookChainPolicy          ChainPolicy
 	home                     string
 }
 
@@ -67,6 +106,12 @@ type ConfigP struct { // for yaml unmarshalling
 	Verbose                  *bool           `yaml:"verbose"`
 	FrontendURL              *string         `yaml:"frontend_url"`
 	Offline                  *bool           `yaml:"offline"`
+	ScanMode                 *ScanMode       `yaml:"scan_mode"`
+	DiffFindRenames          *bool           `yaml:"diff_find_renames"`
+	ScanSubmodules           *bool           `yaml:"scan_submodules"`
+	ScanTimeout              *string         `yaml:"scan_timeout"`
+	OnScanFailure            *FailurePolicy  `yaml:"on_scan_failure"`
+	HookChainPolicy          *ChainPolicy    `yaml:"hook_chain_policy"`
 }
 
 func NewConfig() Config {
@@ -84,6 +129,11 @@ func NewConfig() Config {
 		SentryLogLevelsToCapture: []sentry.Level{"error", "fatal"},
 		Verbose:                  verbose == "true",
 		Offline:                  offline == "true",
+		ScanMode:                 ScanHistory,
+		DiffFindRenames:          true,
+		ScanTimeout:              2 * time.Minute,
+		OnScanFailure:            FailOpen,
+		HookChainPolicy:          ChainStopOnFailure,
 	}
 }
 
@@ -91,13 +141,15 @@ func (c Config) WithRuntimeFlags() Config {
 	return c.WithRuntimeYAML()
 }
 
-func (c Config) WithRuntimeYAML() Config {
-	configFiles := []string{
+func (c *Config) runtimeYAMLFiles() []string {
+	return []string{
 		filepath.Join(c.Home(), "config.yaml"),
 		filepath.Join(c.Home(), "config.yml"),
 	}
+}
 
-	for _, configFile := range configFiles {
+func (c Config) WithRuntimeYAML() Config {
+	for _, configFile := range c.runtimeYAMLFiles() {
 		data, err := os.ReadFile(configFile)
 		if err != nil {
 			continue
@@ -142,6 +194,26 @@ func (c Config) WithRuntimeYAML() Config {
 		if configYaml.Offline != nil {
 			c.Offline = *configYaml.Offline
 		}
+		if configYaml.ScanMode != nil {
+			c.ScanMode = *configYaml.ScanMode
+		}
+		if configYaml.DiffFindRenames != nil {
+			c.DiffFindRenames = *configYaml.DiffFindRenames
+		}
+		if configYaml.ScanSubmodules != nil {
+			c.ScanSubmodules = *configYaml.ScanSubmodules
+		}
+		if configYaml.ScanTimeout != nil {
+			if timeout, err := time.ParseDuration(*configYaml.ScanTimeout); err == nil {
+				c.ScanTimeout = timeout
+			}
+		}
+		if configYaml.OnScanFailure != nil {
+			c.OnScanFailure = *configYaml.OnScanFailure
+		}
+		if configYaml.HookChainPolicy != nil {
+			c.HookChainPolicy = *configYaml.HookChainPolicy
+		}
 
 		break
 	}
@@ -149,6 +221,52 @@ func (c Config) WithRuntimeYAML() Config {
 	return c
 }
 
+// CheckRuntimeYAML reports problems WithRuntimeYAML silently ignores:
+// parse errors, unknown keys and invalid values.
+// Returns the config file checked, empty if there is none.
+func (c *Config) CheckRuntimeYAML() (string, error) {
+	for _, configFile := range c.runtimeYAMLFiles() {
+		data, err := os.ReadFile(configFile)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return configFile, err
+		}
+
+		configYaml := ConfigP{}
+		if err := yaml.UnmarshalWithOptions(data, &configYaml, yaml.DisallowUnknownField()); err != nil {
+			return configFile, err
+		}
+
+		invalid := func(key, value string, expected ...string) error {
+			return fmt.Errorf("invalid %s: %q, expected one of %v", key, value, expected)
+		}
+		if v := configYaml.Environment; v != nil && *v != Dev && *v != Rel {
+			return configFile, invalid("environment", string(*v), string(Dev), string(Rel))
+		}
+		if v := configYaml.ScanMode; v != nil && *v != ScanHistory && *v != ScanDiff {
+			return configFile, invalid("scan_mode", string(*v), string(ScanHistory), string(ScanDiff))
+		}
+		if v := configYaml.OnScanFailure; v != nil && *v != FailOpen && *v != FailClosed {
+			return configFile, invalid("on_scan_failure", string(*v), string(FailOpen), string(FailClosed))
+		}
+		if v := configYaml.HookChainPolicy; v != nil && *v != ChainStopOnFailure && *v != ChainRunAll {
+			return configFile, invalid("hook_chain_policy", string(*v), string(ChainStopOnFailure), string(ChainRunAll))
+		}
+		if v := configYaml.Autoupdate; v != nil && *v != "on" && *v != "off" && *v != "notify" {
+			return configFile, invalid("autoupdate", *v, "on", "off", "notify")
+		}
+		if v := configYaml.ScanTimeout; v != nil {
+			if _, err := time.ParseDuration(*v); err != nil {
+				return configFile, fmt.Errorf("invalid scan_timeout: %w", err)
+			}
+		}
+		return configFile, nil
+	}
+	return "", nil
+}
+
 func (c Config) WithDebugFlags() Config {
 	c.Autoupdate = "off"
 	c.SentryDsn = ""
@@ -87,6 +87,10 @@ func WriteExecutableFileWithContent(filename, content string) error {
 	return atomicWrite(filename, content, 0755)
 }
 
+func WriteFileWithContent(filename, content string) error {
+	return atomicWrite(filename, content, 0644)
+}
+
 func SameFile(f1_name, f2_name string) bool {
 	f1, err := os.Stat(f1_name)
 	if err != nil {
@@ -79,6 +79,11 @@ func (s *AxiFS) APIKeyPath() string {
 	return filepath.Join(s.Home, "api_key")
 }
 
+// ReposPath is the registry of repos axi set up
+func (s *AxiFS) ReposPath() string {
+	return filepath.Join(s.Home, "repos.json")
+}
+
 func (s *AxiFS) HooksDir() string {
 	return filepath.Join(s.Home, "hooks")
 }
@@ -0,0 +1,563 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/axilock/axi/internal/context"
+)
+
+var ErrObjectMissing = errors.New("object missing")
+
+// NoLazyFetchEnv stops git from fetching objects missing in partial clones
+const NoLazyFetchEnv = "GIT_NO_LAZY_FETCH=1"
+
+type ObjectInfo struct {
+	OID  string
+	Type string
+	Size int64
+}
+
+type Signature struct {
+	Name  string
+	Email string
+	Time  time.Time
+}
+
+type CommitObject struct {
+	ID        string
+	Tree      string
+	Parents   []string
+	Author    Signature
+	Committer Signature
+	Message   string
+}
+
+// Commit returns the summary used while reporting pushed commits.
+// Author is the committer email, time is the author time (same as
+// `git log --pretty=%ce %ai`)
+func (c *CommitObject) Commit() Commit {
+	return Commit{
+		ID:     c.ID,
+		Author: c.Committer.Email,
+		Time:   c.Author.Time,
+	}
+}
+
+type TreeEntry struct {
+	Mode string
+	Name string
+	OID  string
+}
+
+func (t *TreeEntry) IsTree() bool {
+	return t.Mode == "40000"
+}
+
+func (t *TreeEntry) IsSubmodule() bool {
+	return t.Mode == "160000"
+}
+
+type ChangeStatus byte
+
+const (
+	Added    ChangeStatus = 'A'
+	Modified ChangeStatus = 'M'
+	Deleted  ChangeStatus = 'D'
+)
+
+type FileChange struct {
+	Status  ChangeStatus
+	Path    string
+	OldMode string
+	NewMode string
+	OldOID  string
+	NewOID  string
+}
+
+// ObjectReader reads objects through long lived `git cat-file --batch`
+// and `git cat-file --batch-check` processes, so that walking a push
+// range does not fork git for every object.
+// Processes are started lazily and must be released with Close.
+type ObjectReader struct {
+	dir   string
+	env   []string
+	mu    sync.Mutex
+	batch *catFile
+	check *catFile
+}
+
+type catFile struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+}
+
+// NewObjectReader creates a reader for the repository at dir.
+// Empty dir means the current working directory.
+// Objects missing in partial clones are never fetched from the promisor
+// remote, they are reported as ErrObjectMissing instead.
+func NewObjectReader(dir string) *ObjectReader {
+	return &ObjectReader{dir: dir, env: []string{NoLazyFetchEnv}}
+}
+
+func startCatFile(dir string, env []string, mode string) (*catFile, error) {
+	var logger = context.Background().Logger()
+
+	logger.V(1).Info("Running git cat-file " + mode)
+
+	cmd := exec.Command("git", "cat-file", mode)
+	cmd.Dir = dir
+	if len(env) > 0 {
+		cmd.Env = append(cmd.Environ(), env...)
+	}
+	cmd.Stderr = io.Discard
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &catFile{cmd: cmd, stdin: stdin, stdout: bufio.NewReader(stdout)}, nil
+}
+
+func (c *catFile) close() error {
+	c.stdin.Close()
+	return c.cmd.Wait()
+}
+
+// request writes rev to the process and parses the
+// "<oid> <type> <size>" / "<rev> missing" response header
+func (c *catFile) request(rev string) (ObjectInfo, error) {
+	if strings.ContainsAny(rev, "\n") {
+		return ObjectInfo{}, fmt.Errorf("invalid revision: %q", rev)
+	}
+	if _, err := io.WriteString(c.stdin, rev+"\n"); err != nil {
+		return ObjectInfo{}, err
+	}
+	line, err := c.stdout.ReadString('\n')
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 2 && (fields[1] == "missing" || fields[1] == "ambiguous") {
+		return ObjectInfo{}, fmt.Errorf("%s: %w", rev, ErrObjectMissing)
+	}
+	if len(fields) != 3 {
+		return ObjectInfo{}, fmt.Errorf("unexpected cat-file output: %q", line)
+	}
+	size, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return ObjectInfo{OID: fields[0], Type: fields[1], Size: size}, nil
+}
+
+// Info returns the object id, type and size of rev without reading its content
+func (r *ObjectReader) Info(rev string) (ObjectInfo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.check == nil {
+		c, err := startCatFile(r.dir, r.env, "--batch-check")
+		if err != nil {
+			return ObjectInfo{}, err
+		}
+		r.check = c
+	}
+	return r.check.request(rev)
+}
+
+// Read returns the object info and raw content of rev
+func (r *ObjectReader) Read(rev string) (ObjectInfo, []byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.batch == nil {
+		c, err := startCatFile(r.dir, r.env, "--batch")
+		if err != nil {
+			return ObjectInfo{}, nil, err
+		}
+		r.batch = c
+	}
+
+	info, err := r.batch.request(rev)
+	if err != nil {
+		return info, nil, err
+	}
+
+	// content is followed by a single LF
+	data := make([]byte, info.Size+1)
+	if _, err := io.ReadFull(r.batch.stdout, data); err != nil {
+		return info, nil, err
+	}
+	return info, data[:info.Size], nil
+}
+
+func (r *ObjectReader) readType(rev, typ string) (ObjectInfo, []byte, error) {
+	info, data, err := r.Read(rev)
+	if err != nil {
+		return info, nil, err
+	}
+	if info.Type != typ {
+		return info, nil, fmt.Errorf("%s is a %s, expected %s", rev, info.Type, typ)
+	}
+	return info, data, nil
+}
+
+func (r *ObjectReader) Commit(rev string) (CommitObject, error) {
+	info, data, err := r.readType(rev+"^{commit}", "commit")
+	if err != nil {
+		return CommitObject{}, err
+	}
+	return parseCommit(info.OID, data), nil
+}
+
+func (r *ObjectReader) Tree(rev string) ([]TreeEntry, error) {
+	info, data, err := r.readType(rev, "tree")
+	if err != nil {
+		return nil, err
+	}
+	return parseTree(data, len(info.OID)/2)
+}
+
+func (r *ObjectReader) Blob(rev string) ([]byte, error) {
+	_, data, err := r.readType(rev, "blob")
+	return data, err
+}
+
+// Commits returns the commits reachable from branch and not from since,
+// newest first. Empty since returns every commit reachable from branch.
+func (r *ObjectReader) Commits(since, branch string) ([]Commit, error) {
+	revs := []string{branch}
+	if since != "" {
+		revs = []string{since + ".." + branch}
+	}
+	ids, err := r.revList(revs...)
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []Commit
+	for _, id := range ids {
+		commit, err := r.Commit(id)
+		if err != nil {
+			return commits, err
+		}
+		commits = append(commits, commit.Commit())
+	}
+	return commits, nil
+}
+
+// LastPushedCommit returns the latest commit reachable by branch that is
+// also present on *any* remote. Only a single rev-list is forked, commit
+// dates of the boundary are read through cat-file.
+// NOTE: Returns empty for root branches (nothing pushed yet)
+func (r *ObjectReader) LastPushedCommit(branch string) (string, error) {
+	lines, err := r.revList("--boundary", branch, "--not", "--remotes")
+	if err != nil {
+		return "", err
+	}
+
+	var boundary []string
+	unpushed := 0
+	for _, line := range lines {
+		if id, ok := strings.CutPrefix(line, "-"); ok {
+			boundary = append(boundary, id)
+		} else {
+			unpushed++
+		}
+	}
+
+	if unpushed == 0 {
+		// branch tip itself is already on a remote
+		info, err := r.Info(branch + "^{commit}")
+		if err != nil {
+			return "", err
+		}
+		return info.OID, nil
+	}
+
+	latest, latestTime := "", time.Time{}
+	for _, id := range boundary {
+		commit, err := r.Commit(id)
+		if err != nil {
+			return "", err
+		}
+		if latest == "" || commit.Committer.Time.After(latestTime) {
+			latest, latestTime = id, commit.Committer.Time
+		}
+	}
+
+	// empty for root commit
+	return latest, nil
+}
+
+// MissingObjects returns the objects needed to diff the commits reachable
+// from branch but not since, which are not available locally (partial
+// clones). Nothing is fetched. Always empty for complete repositories.
+func (r *ObjectReader) MissingObjects(since, branch string) (map[string]bool, error) {
+	missing := make(map[string]bool)
+	if !isPromisor(r.dir) {
+		return missing, nil
+	}
+
+	revs := []string{branch}
+	if since != "" {
+		revs = []string{since + ".." + branch}
+	}
+	lines, err := r.revList(append([]string{"--objects", "--missing=print"}, revs...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// objects of the parents of the oldest commits (not listed above)
+	boundary, err := r.revList(append([]string{"--boundary"}, revs...)...)
+	if err != nil {
+		return nil, err
+	}
+	var parents []string
+	for _, line := range boundary {
+		if id, ok := strings.CutPrefix(line, "-"); ok {
+			parents = append(parents, id)
+		}
+	}
+	if len(parents) > 0 {
+		parentLines, err := r.revList(append([]string{"--objects", "--missing=print", "--no-walk"}, parents...)...)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, parentLines...)
+	}
+
+	for _, line := range lines {
+		if id, ok := strings.CutPrefix(line, "?"); ok {
+			missing[id] = true
+		}
+	}
+	return missing, nil
+}
+
+// Diff compares the trees of two commits and returns the changed files.
+// Empty from compares against the empty tree.
+// Submodule entries are reported as files with mode 160000.
+func (r *ObjectReader) Diff(from, to string) ([]FileChange, error) {
+	toCommit, err := r.Commit(to)
+	if err != nil {
+		return nil, err
+	}
+	fromTree := ""
+	if from != "" {
+		fromCommit, err := r.Commit(from)
+		if err != nil {
+			return nil, err
+		}
+		fromTree = fromCommit.Tree
+	}
+
+	var changes []FileChange
+	err = r.diffTrees("", fromTree, toCommit.Tree, &changes)
+	return changes, err
+}
+
+func (r *ObjectReader) diffTrees(prefix, from, to string, changes *[]FileChange) error {
+	if from == to {
+		return nil
+	}
+	oldEntries, err := r.treeEntries(from)
+	if err != nil {
+		return err
+	}
+	newEntries, err := r.treeEntries(to)
+	if err != nil {
+		return err
+	}
+
+	names := make(map[string]bool)
+	for name := range oldEntries {
+		names[name] = true
+	}
+	for name := range newEntries {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	for _, name := range sorted {
+		o, inOld := oldEntries[name]
+		n, inNew := newEntries[name]
+		p := path.Join(prefix, name)
+
+		if inOld && inNew && o.OID == n.OID && o.Mode == n.Mode {
+			continue
+		}
+
+		oldTree, newTree := "", ""
+		if inOld && o.IsTree() {
+			oldTree = o.OID
+			inOld = false
+		}
+		if inNew && n.IsTree() {
+			newTree = n.OID
+			inNew = false
+		}
+		if oldTree != "" || newTree != "" {
+			if err := r.diffTrees(p, oldTree, newTree, changes); err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case inOld && inNew:
+			*changes = append(*changes, FileChange{Status: Modified, Path: p,
+				OldMode: o.Mode, NewMode: n.Mode, OldOID: o.OID, NewOID: n.OID})
+		case inNew:
+			*changes = append(*changes, FileChange{Status: Added, Path: p,
+				NewMode: n.Mode, NewOID: n.OID})
+		case inOld:
+			*changes = append(*changes, FileChange{Status: Deleted, Path: p,
+				OldMode: o.Mode, OldOID: o.OID})
+		}
+	}
+	return nil
+}
+
+func (r *ObjectReader) treeEntries(oid string) (map[string]TreeEntry, error) {
+	entries := make(map[string]TreeEntry)
+	if oid == "" {
+		return entries, nil
+	}
+	tree, err := r.Tree(oid)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range tree {
+		entries[e.Name] = e
+	}
+	return entries, nil
+}
+
+func (r *ObjectReader) revList(args ...string) ([]string, error) {
+	out, err := execGitIn(r.dir, r.env, append([]string{"rev-list"}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, id := range strings.Split(out, "\n") {
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// Close stops the cat-file processes
+func (r *ObjectReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var errs []error
+	if r.batch != nil {
+		errs = append(errs, r.batch.close())
+		r.batch = nil
+	}
+	if r.check != nil {
+		errs = append(errs, r.check.close())
+		r.check = nil
+	}
+	return errors.Join(errs...)
+}
+
+func parseCommit(oid string, data []byte) CommitObject {
+	commit := CommitObject{ID: oid}
+	header, message, _ := bytes.Cut(data, []byte("\n\n"))
+	commit.Message = string(message)
+
+	for _, line := range strings.Split(string(header), "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "tree":
+			commit.Tree = value
+		case "parent":
+			commit.Parents = append(commit.Parents, value)
+		case "author":
+			commit.Author = parseSignature(value)
+		case "committer":
+			commit.Committer = parseSignature(value)
+		}
+	}
+	return commit
+}
+
+// parseSignature parses "Name <email> 1700000000 +0530"
+func parseSignature(value string) Signature {
+	var sig Signature
+	start := strings.Index(value, "<")
+	end := strings.LastIndex(value, ">")
+	if start < 0 || end < start {
+		return sig
+	}
+	sig.Name = strings.TrimSpace(value[:start])
+	sig.Email = value[start+1 : end]
+
+	fields := strings.Fields(value[end+1:])
+	if len(fields) != 2 {
+		return sig
+	}
+	secs, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return sig
+	}
+	loc := time.UTC
+	if tz, err := time.Parse("-0700", fields[1]); err == nil {
+		_, offset := tz.Zone()
+		loc = time.FixedZone(fields[1], offset)
+	}
+	sig.Time = time.Unix(secs, 0).In(loc)
+	return sig
+}
+
+// parseTree parses the binary tree format: "<mode> <name>\0<raw oid>"
+func parseTree(data []byte, hashSize int) ([]TreeEntry, error) {
+	var entries []TreeEntry
+	for len(data) > 0 {
+		sp := bytes.IndexByte(data, ' ')
+		if sp < 0 {
+			return nil, errors.New("malformed tree: missing mode")
+		}
+		nul := bytes.IndexByte(data[sp:], 0)
+		if nul < 0 {
+			return nil, errors.New("malformed tree: missing name")
+		}
+		nul += sp
+		if len(data) < nul+1+hashSize {
+			return nil, errors.New("malformed tree: truncated object id")
+		}
+		entries = append(entries, TreeEntry{
+			Mode: string(data[:sp]),
+			Name: string(data[sp+1 : nul]),
+			OID:  hex.EncodeToString(data[nul+1 : nul+1+hashSize]),
+		})
+		data = data[nul+1+hashSize:]
+	}
+	return entries, nil
+}
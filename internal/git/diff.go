@@ -0,0 +1,197 @@
+package git
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+)
+
+type AddedLine struct {
+	Line int
+	Text string
+}
+
+// FileAdditions are the lines a commit added to a file,
+// numbered as per the file in that commit
+type FileAdditions struct {
+	Path  string
+	Lines []AddedLine
+}
+
+// AddedLines returns only the lines introduced by commit, using
+// `git diff-tree -p` against its parent(s).
+// With findRenames, moved files do not show up as additions.
+// For merges, only lines new to every parent (evil merges) are returned.
+func (r *ObjectReader) AddedLines(commit string, findRenames bool) ([]FileAdditions, error) {
+	return r.diffTree(commit, findRenames)
+}
+
+func (r *ObjectReader) diffTree(commit string, findRenames bool, pathspecs ...string) ([]FileAdditions, error) {
+	renames := "--no-renames"
+	if findRenames {
+		renames = "--find-renames"
+	}
+	args := []string{
+		"-c", "core.quotePath=false",
+		"diff-tree", "-p", "-U0", "--root", "--cc",
+		"--no-commit-id", "--no-color", "--no-ext-diff",
+		renames, commit,
+	}
+	if len(pathspecs) > 0 {
+		args = append(append(args, "--"), pathspecs...)
+	}
+	out, err := execGitIn(r.dir, r.env, args...)
+	if err != nil {
+		return nil, err
+	}
+	return parseAddedLines(out), nil
+}
+
+// AddedLinesPresent is AddedLines for partial clones: files whose content
+// is in missing are not read (that would trigger a lazy fetch), their
+// paths are returned as skipped instead.
+// A file whose previous version is missing is returned whole.
+func (r *ObjectReader) AddedLinesPresent(commit string, findRenames bool, missing map[string]bool) ([]FileAdditions, []string, error) {
+	if len(missing) == 0 {
+		additions, err := r.AddedLines(commit, findRenames)
+		return additions, nil, err
+	}
+
+	c, err := r.Commit(commit)
+	if err != nil {
+		return nil, nil, err
+	}
+	parent := ""
+	if len(c.Parents) > 0 {
+		parent = c.Parents[0]
+	}
+	changes, err := r.Diff(parent, c.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var pathspecs, skipped []string
+	var whole []FileAdditions
+	for _, change := range changes {
+		if change.NewMode == "160000" || change.OldMode == "160000" {
+			continue
+		}
+		oldMissing := change.OldOID != "" && missing[change.OldOID]
+		newMissing := change.NewOID != "" && missing[change.NewOID]
+		switch {
+		case change.Status == Deleted:
+			if !oldMissing {
+				// keeps rename detection working
+				pathspecs = append(pathspecs, ":(literal)"+change.Path)
+			}
+		case newMissing:
+			skipped = append(skipped, change.Path)
+		case oldMissing:
+			blob, err := r.Blob(change.NewOID)
+			if err != nil {
+				return nil, nil, err
+			}
+			whole = append(whole, wholeFile(change.Path, blob))
+		default:
+			pathspecs = append(pathspecs, ":(literal)"+change.Path)
+		}
+	}
+
+	additions := whole
+	if len(pathspecs) > 0 {
+		diffed, err := r.diffTree(c.ID, findRenames, pathspecs...)
+		if err != nil {
+			return nil, nil, err
+		}
+		additions = append(additions, diffed...)
+	}
+	return additions, skipped, nil
+}
+
+func wholeFile(path string, content []byte) FileAdditions {
+	file := FileAdditions{Path: path}
+	for i, line := range strings.Split(strings.TrimSuffix(string(content), "\n"), "\n") {
+		file.Lines = append(file.Lines, AddedLine{Line: i + 1, Text: line})
+	}
+	return file
+}
+
+func parseAddedLines(patch string) []FileAdditions {
+	var files []FileAdditions
+	var current *FileAdditions
+	parents := 1
+	lineNo := 0
+
+	scanner := bufio.NewScanner(strings.NewReader(patch))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "diff "):
+			current = nil
+			parents = 1
+			if strings.HasPrefix(line, "diff --cc ") || strings.HasPrefix(line, "diff --combined ") {
+				parents = 2
+			}
+		case current == nil && strings.HasPrefix(line, "+++ "):
+			path := strings.TrimPrefix(line, "+++ ")
+			if path == "/dev/null" {
+				continue
+			}
+			path = unquotePath(path)
+			path = strings.TrimPrefix(path, "b/")
+			files = append(files, FileAdditions{Path: path})
+			current = &files[len(files)-1]
+		case current != nil && strings.HasPrefix(line, "@@"):
+			parents = strings.IndexFunc(line, func(r rune) bool { return r != '@' }) - 1
+			lineNo = hunkStart(line)
+		case strings.HasPrefix(line, "\\"):
+			// "\ No newline at end of file"
+			continue
+		case current != nil && len(line) >= parents:
+			prefix := line[:parents]
+			if strings.Trim(prefix, "+") == "" {
+				// new to all parents
+				current.Lines = append(current.Lines, AddedLine{Line: lineNo, Text: line[parents:]})
+				lineNo++
+			} else if !strings.Contains(prefix, "-") {
+				// context or line coming from one of the parents
+				lineNo++
+			}
+		}
+	}
+
+	var nonEmpty []FileAdditions
+	for _, f := range files {
+		if len(f.Lines) > 0 {
+			nonEmpty = append(nonEmpty, f)
+		}
+	}
+	return nonEmpty
+}
+
+// hunkStart returns the first line of the new file from a
+// "@@ -a,b +c,d @@" (or combined "@@@ -a,b -c,d +e,f @@@") header
+func hunkStart(header string) int {
+	for _, field := range strings.Fields(header) {
+		if !strings.HasPrefix(field, "+") {
+			continue
+		}
+		start, _, _ := strings.Cut(field[1:], ",")
+		n, err := strconv.Atoi(start)
+		if err != nil {
+			return 0
+		}
+		return n
+	}
+	return 0
+}
+
+func unquotePath(path string) string {
+	if strings.HasPrefix(path, `"`) {
+		if unquoted, err := strconv.Unquote(path); err == nil {
+			return unquoted
+		}
+	}
+	return path
+}
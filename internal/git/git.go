@@ -31,6 +31,32 @@ type Commit struct {
 	Time   time.Time
 }
 
+// HookNames are the hooks git runs from core.hooksPath
+var HookNames = []string{
+	"applypatch-msg",
+	"commit-msg",
+	"fsmonitor-watchman",
+	"post-applypatch",
+	"post-checkout",
+	"post-commit",
+	"post-merge",
+	"post-receive",
+	"post-rewrite",
+	"post-update",
+	"pre-applypatch",
+	"pre-auto-gc",
+	"pre-commit",
+	"pre-merge-commit",
+	"pre-push",
+	"pre-rebase",
+	"pre-receive",
+	"prepare-commit-msg",
+	"push-to-checkout",
+	"reference-transaction",
+	"sendemail-validate",
+	"update",
+}
+
 // Set local core hooks path
 // NOTE: Must use a relative path to git top level
 // This ensures local config stays local to the repo
@@ -65,6 +91,69 @@ func GetCoreHooksPath() (string, error) {
 	return execGitConfig("core.hooksPath")
 }
 
+// axi.chainHooksPath is the core.hooksPath a hook manager (husky...)
+// had set before axi took over the repo's hooks, as it was configured
+func SetLocalChainHooksPath(path string) error {
+	_, err := execGitConfig("--local", "axi.chainHooksPath", path)
+	return err
+}
+
+func GetLocalChainHooksPath() (string, error) {
+	return execGitConfig("--local", "axi.chainHooksPath")
+}
+
+func UnsetLocalChainHooksPath() error {
+	_, err := execGitConfig("--local", "--unset", "axi.chainHooksPath")
+	return err
+}
+
+// axi.previousHooksPath is the global core.hooksPath set before axi's install
+func SetGlobalPreviousHooksPath(path string) error {
+	_, err := execGitConfig("--global", "axi.previousHooksPath", path)
+	return err
+}
+
+func GetGlobalPreviousHooksPath() (string, error) {
+	return execGitConfig("--global", "axi.previousHooksPath")
+}
+
+func UnsetGlobalPreviousHooksPath() error {
+	_, err := execGitConfig("--global", "--unset", "axi.previousHooksPath")
+	return err
+}
+
+// axi.repoId identifies the repo in axi's registry of enrolled repos,
+// it stays the same when the repo is moved
+func SetLocalRepoID(id string) error {
+	_, err := execGitConfig("--local", "axi.repoId", id)
+	return err
+}
+
+func GetLocalRepoID() (string, error) {
+	return execGitConfig("--local", "axi.repoId")
+}
+
+func UnsetLocalRepoID() error {
+	_, err := execGitConfig("--local", "--unset", "axi.repoId")
+	return err
+}
+
+// axi.disabled turns axi's protection off in the repo, see `axi repos disable`
+func SetLocalDisabled() error {
+	_, err := execGitConfig("--local", "--type=bool", "axi.disabled", "true")
+	return err
+}
+
+func GetLocalDisabled() (bool, error) {
+	value, err := execGitConfig("--local", "--type=bool", "axi.disabled")
+	return value == "true", err
+}
+
+func UnsetLocalDisabled() error {
+	_, err := execGitConfig("--local", "--unset", "axi.disabled")
+	return err
+}
+
 func GetRemoteUrl(name string) (string, error) {
 	return execGitConfig("--get", "remote."+name+".url")
 }
@@ -77,74 +166,23 @@ func GetRevList(fromTo ...string) (string, error) {
 }
 
 // Return commit list with author
-// FIXME: since can be empty string, in this case return all commits reachable from
-// current branch
+// Empty since returns all commits reachable from branch
 func GetCommitsList(since, branch string) []Commit {
-	var log string
-
-	if since == "" {
-		log, _ = execGit("log", "--pretty=format:%H%x00%ce%x00%ai", "--date=iso-strict", branch)
-	} else {
-		log, _ = execGit("log", "--pretty=format:%H%x00%ce%x00%ai", "--date=iso-strict", since+".."+branch)
-	}
-
-	var commits []Commit
-	lines := strings.Split(log, "\n")
-	for _, line := range lines {
-		parts := strings.Split(line, "\x00")
-		if len(parts) != 3 {
-			continue
-		}
+	reader := NewObjectReader("")
+	defer reader.Close()
 
-		t, err := time.Parse(`2006-01-02 15:04:05 -0700`, string(parts[2]))
-		if err != nil {
-			t = time.Time{}
-		}
-		commits = append(commits, Commit{
-			ID:     parts[0],
-			Author: parts[1],
-			Time:   t,
-		})
-	}
+	commits, _ := reader.Commits(since, branch)
 	return commits
-
 }
 
 // LastPushedCommitReachableByBranch returns the SHA of the latest commit that is both
 // in your local HEAD and present on *any* remote in your repo.
 // NOTE: Can return empty in case of errors (no branch etc) or root branch
-// FIXME: In large repos, this can be slow. We should consider limiting
-// rev-list output
 func LastPushedCommitReachableByBranch(branch string) (string, error) {
-	localCommitsStr, err := execGit("rev-list", "--date-order", branch)
-	if err != nil {
-		return "", err
-	}
-	localCommits := strings.Split(localCommitsStr, "\n")
-
-	if len(localCommits) == 0 {
-		return "", nil
-	}
-
-	notRemoteStr, err := execGit("rev-list", branch, "--not", "--remotes")
-	if err != nil {
-		return "", err
-	}
-	notRemoteSet := make(map[string]bool)
-	for _, c := range strings.Split(notRemoteStr, "\n") {
-		if c != "" {
-			notRemoteSet[c] = true
-		}
-	}
+	reader := NewObjectReader("")
+	defer reader.Close()
 
-	for _, commit := range localCommits {
-		if commit != "" && !notRemoteSet[commit] {
-			return commit, nil
-		}
-	}
-
-	// root commit
-	return "", nil
+	return reader.LastPushedCommit(branch)
 }
 
 func execGitConfig(args ...string) (string, error) {
@@ -167,12 +205,21 @@ func execGitConfig(args ...string) (string, error) {
 }
 
 func execGit(args ...string) (string, error) {
+	return execGitIn("", nil, args...)
+}
+
+// execGitIn runs git in dir with extra environment variables
+func execGitIn(dir string, env []string, args ...string) (string, error) {
 	var logger = context.Background().Logger()
 
 	logger.V(1).Info("Running git " + strings.Join(args, " "))
 
 	var stdout bytes.Buffer
 	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if len(env) > 0 {
+		cmd.Env = append(cmd.Environ(), env...)
+	}
 	cmd.Stdout = &stdout
 	cmd.Stderr = io.Discard
 	err := cmd.Run()
@@ -214,6 +261,11 @@ func DirRelToGitTopLevel(absDir string) (string, error) {
 		return "", err
 	}
 
+	// absDir is usually symlink free (eg: submodule git dirs)
+	if realTopLevel, err := filepath.EvalSymlinks(topLevel); err == nil {
+		topLevel = realTopLevel
+	}
+
 	relDir, err := filepath.Rel(topLevel, absDir)
 	if err != nil {
 		return "", err
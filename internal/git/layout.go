@@ -0,0 +1,223 @@
+package git
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Layout describes where the pieces of the current repository live.
+// It is resolved by git itself, so it honours GIT_DIR / GIT_WORK_TREE,
+// linked worktrees (`git worktree add`), submodules and bare repos.
+// All paths are absolute with symlinks resolved.
+type Layout struct {
+	// GitDir is the per worktree git dir (.git/worktrees/<name> for linked worktrees)
+	GitDir string
+	// CommonDir holds config, objects and hooks shared by all worktrees
+	CommonDir string
+	// TopLevel is the root of the working tree, empty for bare repos
+	TopLevel string
+	// MainTopLevel is the root of the main working tree, differs from
+	// TopLevel only in linked worktrees
+	MainTopLevel string
+	Bare         bool
+	// Shallow repos (clone --depth) have truncated history
+	Shallow bool
+	// Promisor repos (clone --filter) lazily fetch missing objects
+	Promisor bool
+}
+
+var ErrNotAGitRepo = errors.New("not a git repository")
+
+func ResolveLayout() (Layout, error) {
+	out, err := execGit("rev-parse", "--absolute-git-dir", "--git-common-dir",
+		"--is-bare-repository", "--is-shallow-repository")
+	if err != nil {
+		return Layout{}, ErrNotAGitRepo
+	}
+	lines := strings.Split(out, "\n")
+	if len(lines) != 4 {
+		return Layout{}, errors.New("unexpected rev-parse output: " + out)
+	}
+
+	layout := Layout{
+		GitDir:    realPath(lines[0]),
+		CommonDir: realPath(lines[1]),
+		Bare:      lines[2] == "true",
+		Shallow:   lines[3] == "true",
+		Promisor:  isPromisor(""),
+	}
+
+	if !layout.Bare {
+		// fails when run from inside the git dir
+		if topLevel, err := GitTopLevel(); err == nil && topLevel != "" {
+			layout.TopLevel = realPath(topLevel)
+		}
+	}
+
+	layout.MainTopLevel = layout.TopLevel
+	if layout.IsLinkedWorktree() {
+		layout.MainTopLevel = mainWorktree()
+	}
+	return layout, nil
+}
+
+// IsLinkedWorktree is true for worktrees created by `git worktree add`
+func (l Layout) IsLinkedWorktree() bool {
+	return l.GitDir != l.CommonDir
+}
+
+// HooksDir is the default hooks dir: $GIT_COMMON_DIR/hooks
+func (l Layout) HooksDir() string {
+	return filepath.Join(l.CommonDir, "hooks")
+}
+
+// RunDir is the directory git runs hooks from, relative
+// core.hooksPath values are resolved against it.
+// Scanners run git from here as well.
+func (l Layout) RunDir() string {
+	if l.TopLevel != "" {
+		return l.TopLevel
+	}
+	return l.GitDir
+}
+
+// Abs resolves a (possibly relative) core.hooksPath value
+func (l Layout) Abs(path string) string {
+	if path == "" {
+		return ""
+	}
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, rest)
+		}
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(l.RunDir(), path)
+	}
+	return realPath(path)
+}
+
+// IsHooksDir checks if a core.hooksPath value points to dir.
+// Relative values written from the main worktree are accepted in linked
+// worktrees as well, although git resolves them per worktree.
+func (l Layout) IsHooksDir(path, dir string) bool {
+	if path == "" {
+		return false
+	}
+	if l.Abs(path) == realPath(dir) {
+		return true
+	}
+	if l.MainTopLevel != "" && !filepath.IsAbs(path) {
+		return realPath(filepath.Join(l.MainTopLevel, path)) == realPath(dir)
+	}
+	return false
+}
+
+// HooksPathConfig is the value to store as local core.hooksPath for dir.
+// A path relative to the top level keeps the config valid when the repo is
+// moved, but relative paths resolve per worktree, so linked worktrees
+// (which share config) and bare repos get an absolute path.
+func (l Layout) HooksPathConfig(dir string) string {
+	if l.TopLevel == "" || l.IsLinkedWorktree() {
+		return dir
+	}
+	rel, err := filepath.Rel(l.TopLevel, dir)
+	if err != nil {
+		return dir
+	}
+	return rel
+}
+
+func realPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		return real
+	}
+	return abs
+}
+
+// mainWorktree returns the main worktree, listed first by git
+func mainWorktree() string {
+	out, err := execGit("worktree", "list", "--porcelain")
+	if err != nil {
+		return ""
+	}
+	first, _, _ := strings.Cut(out, "\n")
+	if path, ok := strings.CutPrefix(first, "worktree "); ok {
+		return realPath(path)
+	}
+	return ""
+}
+
+// isPromisor checks if the repository at dir is a partial clone
+func isPromisor(dir string) bool {
+	if filter, _ := execGitIn(dir, nil, "config", "--get", "extensions.partialClone"); filter != "" {
+		return true
+	}
+	promisors, _ := execGitIn(dir, nil, "config", "--get-regexp", `^remote\..*\.promisor$`)
+	for _, line := range strings.Split(promisors, "\n") {
+		if strings.HasSuffix(line, " true") {
+			return true
+		}
+	}
+	return false
+}
+
+// ShallowCommits returns the commits at the shallow boundary,
+// their parents are not present locally
+func (l Layout) ShallowCommits() map[string]bool {
+	shallow := make(map[string]bool)
+	data, err := os.ReadFile(filepath.Join(l.CommonDir, "shallow"))
+	if err != nil {
+		return shallow
+	}
+	for _, id := range strings.Fields(string(data)) {
+		shallow[id] = true
+	}
+	return shallow
+}
+
+// LastPush is when a push last updated a remote-tracking branch, as
+// recorded in their reflogs. Zero if unknown: pushes to a url rather
+// than a remote do not update remote-tracking branches.
+func (l Layout) LastPush() time.Time {
+	var last time.Time
+	filepath.WalkDir(filepath.Join(l.CommonDir, "logs", "refs", "remotes"),
+		func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return nil
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil
+			}
+			// <old> <new> <name> <<email>> <timestamp> <tz>\t<message>
+			for _, line := range strings.Split(string(data), "\n") {
+				entry, message, ok := strings.Cut(line, "\t")
+				if !ok || message != "update by push" {
+					continue
+				}
+				fields := strings.Fields(entry)
+				if len(fields) < 2 {
+					continue
+				}
+				seconds, err := strconv.ParseInt(fields[len(fields)-2], 10, 64)
+				if err != nil {
+					continue
+				}
+				if at := time.Unix(seconds, 0); at.After(last) {
+					last = at
+				}
+			}
+			return nil
+		})
+	return last
+}
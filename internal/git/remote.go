@@ -0,0 +1,209 @@
+package git
+
+import (
+	"sort"
+	"strings"
+)
+
+// Remote is a configured remote along with the URLs git actually
+// uses for it, after url.<base>.insteadOf / pushInsteadOf rewrites.
+// Credentials embedded in the urls are removed.
+type Remote struct {
+	Name              string   `json:"name"`
+	Canonical         string   `json:"canonical"`           // see CanonicalRepoURL
+	URL               string   `json:"url"`                 // remote.<name>.url as configured
+	PushURLs          []string `json:"push_urls,omitempty"` // remote.<name>.pushurl as configured
+	FetchURL          string   `json:"fetch_url"`
+	EffectivePushURLs []string `json:"effective_push_urls"`
+	Roles             []string `json:"roles"`               // fetch, push, default-push
+	Rewritten         bool     `json:"rewritten,omitempty"` // any url changed by insteadOf
+}
+
+type urlRewrite struct {
+	base      string
+	insteadOf string
+	push      bool
+}
+
+// Remotes returns all configured remotes, sorted by name
+func Remotes() ([]Remote, error) {
+	out, err := execGit("config", "--get-regexp", `^remote\.`)
+	if err != nil {
+		// exit code 1: no remotes configured
+		return nil, nil
+	}
+
+	byName := make(map[string]*Remote)
+	for _, line := range strings.Split(out, "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		rest := strings.TrimPrefix(key, "remote.")
+		dot := strings.LastIndex(rest, ".")
+		if dot < 0 {
+			continue
+		}
+		name, variable := rest[:dot], rest[dot+1:]
+
+		remote, ok := byName[name]
+		if !ok {
+			remote = &Remote{Name: name}
+			byName[name] = remote
+		}
+		switch variable {
+		case "url":
+			remote.URL = value
+		case "pushurl":
+			remote.PushURLs = append(remote.PushURLs, value)
+		}
+	}
+
+	rewrites := urlRewrites()
+	defaultPush := PushRemote()
+	var remotes []Remote
+	for _, remote := range byName {
+		if remote.URL == "" && len(remote.PushURLs) == 0 {
+			continue
+		}
+
+		remote.FetchURL = rewriteURL(remote.URL, rewrites, false)
+		remote.Rewritten = remote.FetchURL != remote.URL
+		if len(remote.PushURLs) > 0 {
+			// pushInsteadOf does not apply to explicit push urls
+			for _, url := range remote.PushURLs {
+				rewritten := rewriteURL(url, rewrites, false)
+				remote.Rewritten = remote.Rewritten || rewritten != url
+				remote.EffectivePushURLs = append(remote.EffectivePushURLs, rewritten)
+			}
+		} else {
+			rewritten := rewriteURL(remote.URL, rewrites, true)
+			remote.Rewritten = remote.Rewritten || rewritten != remote.URL
+			remote.EffectivePushURLs = []string{rewritten}
+		}
+
+		if remote.URL != "" {
+			remote.Roles = append(remote.Roles, "fetch")
+		}
+		remote.Roles = append(remote.Roles, "push")
+		if remote.Name == defaultPush {
+			remote.Roles = append(remote.Roles, "default-push")
+		}
+
+		remote.Canonical = CanonicalRepoURL(remote.EffectivePushURLs[0])
+		remote.URL = StripCredentials(remote.URL)
+		remote.FetchURL = StripCredentials(remote.FetchURL)
+		for i := range remote.PushURLs {
+			remote.PushURLs[i] = StripCredentials(remote.PushURLs[i])
+		}
+		for i := range remote.EffectivePushURLs {
+			remote.EffectivePushURLs[i] = StripCredentials(remote.EffectivePushURLs[i])
+		}
+		remotes = append(remotes, *remote)
+	}
+
+	sort.Slice(remotes, func(i, j int) bool { return remotes[i].Name < remotes[j].Name })
+	return remotes, nil
+}
+
+// RewriteURL applies url.<base>.insteadOf rules (and pushInsteadOf
+// rules if push is set) the way git does: longest matching prefix wins
+func RewriteURL(url string, push bool) string {
+	return rewriteURL(url, urlRewrites(), push)
+}
+
+func urlRewrites() []urlRewrite {
+	out, err := execGit("config", "--get-regexp", `^url\..*\.(insteadof|pushinsteadof)$`)
+	if err != nil {
+		return nil
+	}
+
+	var rewrites []urlRewrite
+	for _, line := range strings.Split(out, "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		rest := strings.TrimPrefix(key, "url.")
+		dot := strings.LastIndex(rest, ".")
+		if dot < 0 {
+			continue
+		}
+		rewrites = append(rewrites, urlRewrite{
+			base:      rest[:dot],
+			insteadOf: value,
+			push:      rest[dot+1:] == "pushinsteadof",
+		})
+	}
+	return rewrites
+}
+
+func rewriteURL(url string, rewrites []urlRewrite, push bool) string {
+	if push {
+		// pushInsteadOf takes precedence over insteadOf for pushes
+		if rewritten, ok := longestRewrite(url, rewrites, true); ok {
+			return rewritten
+		}
+	}
+	if rewritten, ok := longestRewrite(url, rewrites, false); ok {
+		return rewritten
+	}
+	return url
+}
+
+func longestRewrite(url string, rewrites []urlRewrite, push bool) (string, bool) {
+	best := -1
+	for i, rw := range rewrites {
+		if rw.push != push || !strings.HasPrefix(url, rw.insteadOf) {
+			continue
+		}
+		if best < 0 || len(rw.insteadOf) > len(rewrites[best].insteadOf) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return url, false
+	}
+	return rewrites[best].base + strings.TrimPrefix(url, rewrites[best].insteadOf), true
+}
+
+// PushRemote returns the remote `git push` uses for the current branch:
+// branch.<name>.pushRemote, remote.pushDefault, branch.<name>.remote,
+// then origin. If none of these exist and there is a single remote,
+// that remote is used. Empty if there are no remotes.
+func PushRemote() string {
+	branch, _ := execGit("symbolic-ref", "--quiet", "--short", "HEAD")
+
+	var candidates []string
+	if branch != "" {
+		candidates = append(candidates, "branch."+branch+".pushRemote")
+	}
+	candidates = append(candidates, "remote.pushDefault")
+	if branch != "" {
+		candidates = append(candidates, "branch."+branch+".remote")
+	}
+	for _, key := range candidates {
+		if remote, _ := execGitConfig("--get", key); remote != "" && remote != "." {
+			return remote
+		}
+	}
+
+	names, _ := execGit("remote")
+	list := strings.Fields(names)
+	for _, name := range list {
+		if name == "origin" {
+			return name
+		}
+	}
+	if len(list) == 1 {
+		return list[0]
+	}
+	return ""
+}
+
+// PushRepoURL returns the canonical identity of the push remote,
+// empty if there is no remote
+func PushRepoURL() string {
+	remotes, _ := Remotes()
+	name := PushRemote()
+	for _, remote := range remotes {
+		if remote.Name == name {
+			return remote.Canonical
+		}
+	}
+	return ""
+}
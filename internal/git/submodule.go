@@ -0,0 +1,42 @@
+package git
+
+// SubmoduleUpdate is a gitlink changed between two superproject commits.
+// From is empty if the submodule was newly added.
+type SubmoduleUpdate struct {
+	Path string
+	From string
+	To   string
+}
+
+// SuperprojectWorkTree returns the top level of the superproject
+// when run inside a submodule, empty otherwise
+func SuperprojectWorkTree() (string, error) {
+	return execGit("rev-parse", "--show-superproject-working-tree")
+}
+
+func IsSubmodule() bool {
+	superproject, _ := SuperprojectWorkTree()
+	return superproject != ""
+}
+
+// SubmoduleUpdates returns the submodule commits newly referenced by branch
+// compared to since. Removed submodules are not reported.
+func (r *ObjectReader) SubmoduleUpdates(since, branch string) ([]SubmoduleUpdate, error) {
+	changes, err := r.Diff(since, branch)
+	if err != nil {
+		return nil, err
+	}
+
+	var updates []SubmoduleUpdate
+	for _, change := range changes {
+		if change.NewMode != "160000" {
+			continue
+		}
+		update := SubmoduleUpdate{Path: change.Path, To: change.NewOID}
+		if change.OldMode == "160000" {
+			update.From = change.OldOID
+		}
+		updates = append(updates, update)
+	}
+	return updates, nil
+}
@@ -0,0 +1,214 @@
+package registry
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/axilock/axi/internal/filesio"
+	"github.com/axilock/axi/internal/git"
+)
+
+// Repo is a repository axi set up: its local core.hooksPath points at
+// its hooks dir, or axi's pre-push script is installed in it
+type Repo struct {
+	ID         string    `json:"id"`               // also in the repo's axi.repoId config
+	Path       string    `json:"path"`             // main worktree, git dir for bare repos
+	GitDir     string    `json:"git_dir"`          // common git dir, holding config and hooks
+	Remote     string    `json:"remote,omitempty"` // canonical url of the push remote
+	EnrolledAt time.Time `json:"enrolled_at"`
+	LastScanAt time.Time `json:"last_scan_at,omitzero"`
+	Disabled   bool      `json:"disabled,omitempty"` // protection turned off, see `axi repos disable`
+}
+
+// Exists tells whether the repo is still where it was last seen
+func (r Repo) Exists() bool {
+	info, err := os.Stat(r.GitDir)
+	return err == nil && info.IsDir()
+}
+
+// Registry is the list of repos axi set up, stored in ~/.axi/repos.json.
+// Git hooks run concurrently, updates are serialized by a lock file.
+type Registry struct {
+	path string
+}
+
+var ErrLocked = errors.New("repo registry is locked by another axi process")
+
+const (
+	lockTimeout = 3 * time.Second
+	// a lock older than this was left by a killed process
+	staleLockAge = 30 * time.Second
+)
+
+func New(home string) *Registry {
+	afs := filesio.AxiFS{Home: home}
+	return &Registry{path: afs.ReposPath()}
+}
+
+// List returns all repos, sorted by path
+func (r *Registry) List() ([]Repo, error) {
+	data, err := os.ReadFile(r.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var repos []Repo
+	if err := json.Unmarshal(data, &repos); err != nil {
+		return nil, err
+	}
+	sort.Slice(repos, func(i, j int) bool { return repos[i].Path < repos[j].Path })
+	return repos, nil
+}
+
+// Update replaces the repos with the ones fn returns, under lock
+func (r *Registry) Update(fn func(repos []Repo) ([]Repo, error)) error {
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	repos, err := r.List()
+	if err != nil {
+		return err
+	}
+	if repos, err = fn(repos); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(repos, "", "  ")
+	if err != nil {
+		return err
+	}
+	return filesio.WriteFileWithContent(r.path, string(data)+"\n")
+}
+
+// Enroll records the current repo, whose layout is given. Its path and
+// remote are updated if it was seen before, even at another path.
+func (r *Registry) Enroll(layout git.Layout, remote string) (Repo, error) {
+	return r.upsert(layout, remote, nil)
+}
+
+// RecordScan records a scan of the current repo, enrolling it if needed
+func (r *Registry) RecordScan(layout git.Layout, remote string, at time.Time) error {
+	_, err := r.upsert(layout, remote, func(repo *Repo) { repo.LastScanAt = at })
+	return err
+}
+
+// SetDisabled records whether protection is turned off in the current
+// repo, enrolling it if needed
+func (r *Registry) SetDisabled(layout git.Layout, remote string, disabled bool) (Repo, error) {
+	return r.upsert(layout, remote, func(repo *Repo) { repo.Disabled = disabled })
+}
+
+// Remove drops repos by id
+func (r *Registry) Remove(ids ...string) error {
+	drop := make(map[string]bool)
+	for _, id := range ids {
+		drop[id] = true
+	}
+	return r.Update(func(repos []Repo) ([]Repo, error) {
+		var kept []Repo
+		for _, repo := range repos {
+			if !drop[repo.ID] {
+				kept = append(kept, repo)
+			}
+		}
+		return kept, nil
+	})
+}
+
+func (r *Registry) upsert(layout git.Layout, remote string, update func(*Repo)) (Repo, error) {
+	path := layout.MainTopLevel
+	if path == "" {
+		path = layout.CommonDir
+	}
+
+	var result Repo
+	err := r.Update(func(repos []Repo) ([]Repo, error) {
+		id, err := git.GetLocalRepoID()
+		if err != nil {
+			return nil, err
+		}
+
+		index := -1
+		for i, repo := range repos {
+			if repo.ID == id && id != "" {
+				index = i
+				break
+			}
+		}
+		// a copy of an enrolled repo has its id, while the original
+		// is still in place. Moved repos are not found at their old path.
+		if index >= 0 && repos[index].GitDir != layout.CommonDir && repos[index].Exists() {
+			index = -1
+			id = ""
+		}
+
+		if id == "" {
+			if id, err = newID(); err != nil {
+				return nil, err
+			}
+			if err := git.SetLocalRepoID(id); err != nil {
+				return nil, err
+			}
+		}
+		if index < 0 {
+			repos = append(repos, Repo{ID: id, EnrolledAt: time.Now()})
+			index = len(repos) - 1
+		}
+
+		repo := &repos[index]
+		repo.Path = path
+		repo.GitDir = layout.CommonDir
+		if remote != "" {
+			repo.Remote = remote
+		}
+		if update != nil {
+			update(repo)
+		}
+		result = *repo
+		return repos, nil
+	})
+	return result, err
+}
+
+func (r *Registry) lock() (func(), error) {
+	lockPath := r.path + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrLocked
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
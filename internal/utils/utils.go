@@ -19,26 +19,26 @@ func SendCoverageData() {
 	defer resp.Body.Close()
 }
 
-func GetSystemMetadataJson(cli_version string) string {
-	type Metadata struct {
-		OS         string    `json:"os"`
-		Arch       string    `json:"arch"`
-		Hostname   string    `json:"hostname"`
-		Username   string    `json:"username"`
-		GoVersion  string    `json:"go_version"`
-		CliVersion string    `json:"cli_version"`
-		NumCPU     int       `json:"num_cpu"`
-		PID        int       `json:"pid"`
-		Timestamp  time.Time `json:"timestamp"`
-	}
+type Metadata struct {
+	OS         string    `json:"os"`
+	Arch       string    `json:"arch"`
+	Hostname   string    `json:"hostname"`
+	Username   string    `json:"username"`
+	GoVersion  string    `json:"go_version"`
+	CliVersion string    `json:"cli_version"`
+	NumCPU     int       `json:"num_cpu"`
+	PID        int       `json:"pid"`
+	Timestamp  time.Time `json:"timestamp"`
+}
 
+func GetSystemMetadata(cli_version string) Metadata {
 	hostname, _ := os.Hostname()
 	username := os.Getenv("USER")
 	if username == "" {
 		username = os.Getenv("USERNAME")
 	}
 
-	metadata := Metadata{
+	return Metadata{
 		OS:         runtime.GOOS,
 		Arch:       runtime.GOARCH,
 		Hostname:   hostname,
@@ -49,8 +49,49 @@ func GetSystemMetadataJson(cli_version string) string {
 		PID:        os.Getpid(),
 		Timestamp:  time.Now(),
 	}
+}
+
+func GetSystemMetadataJson(cli_version string) string {
+	metadataJSON, err := json.Marshal(GetSystemMetadata(cli_version))
+	if err != nil {
+		return ""
+	}
+
+	return string(metadataJSON)
+}
+
+// GetEventMetadataJson is system metadata along with a client side event
+// (eg: scan failures), reported to backend as repo metadata
+func GetEventMetadataJson(cli_version, event string, details map[string]string) string {
+	type EventMetadata struct {
+		Metadata
+		Event   string            `json:"event"`
+		Details map[string]string `json:"details,omitempty"`
+	}
+
+	metadataJSON, err := json.Marshal(EventMetadata{
+		Metadata: GetSystemMetadata(cli_version),
+		Event:    event,
+		Details:  details,
+	})
+	if err != nil {
+		return ""
+	}
+
+	return string(metadataJSON)
+}
+
+// GetRepoMetadataJson is system metadata along with all the remotes of a repo
+func GetRepoMetadataJson(cli_version string, remotes any) string {
+	type RepoMetadata struct {
+		Metadata
+		Remotes any `json:"remotes"`
+	}
 
-	metadataJSON, err := json.Marshal(metadata)
+	metadataJSON, err := json.Marshal(RepoMetadata{
+		Metadata: GetSystemMetadata(cli_version),
+		Remotes:  remotes,
+	})
 	if err != nil {
 		return ""
 	}
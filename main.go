@@ -32,6 +32,8 @@ type CLI struct {
 	Install   InstallCmd   `cmd:"" help:"Install axi"`
 	Uninstall UninstallCmd `cmd:"" help:"Uninstall axi"`
 	Reinstall ReInstallCmd `cmd:"" help:"Reinstall axi"`
+	Doctor    DoctorCmd    `cmd:"" help:"Diagnose axi installation"`
+	Repos     ReposCmd     `cmd:"" help:"List and manage repos axi set up"`
 
 	Hook HookCmd `cmd:"" help:"Trigger axi-built hook"`
 
@@ -217,7 +219,6 @@ func NewRetCode(err error) int {
 	var corruptedHook *hooks.ErrCorruptedHook
 	var hookError *hooks.HookError
 
-	// TODO: In case of non-secret errors, suggest running doctor
 	if errors.As(err, &corruptedHook) ||
 		errors.As(err, &unsupportedConfiguration) ||
 		errors.As(err, &trufflehogError) ||
@@ -225,6 +226,7 @@ func NewRetCode(err error) int {
 		errors.As(err, &unsupportedHook) {
 		logger.Error(err, "Irrecoverable error.")
 		fmt.Println(err.Error())
+		fmt.Println("Run `~/.axi/bin/axi doctor` to diagnose your installation.")
 		return 0
 	}
 
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/axilock/axi/hooks"
+	"github.com/axilock/axi/internal/config"
+	"github.com/axilock/axi/internal/context"
+	"github.com/axilock/axi/internal/git"
+	"github.com/axilock/axi/internal/registry"
+	"github.com/axilock/axi/internal/utils"
+	pb "github.com/axilock/axilock-protos/client"
+	"google.golang.org/grpc"
+)
+
+// Kong bindings
+type ReposCmd struct {
+	List    ReposListCmd    `cmd:"" help:"List repos axi set up"`
+	Enable  ReposEnableCmd  `cmd:"" help:"Turn protection on in a repo"`
+	Disable ReposDisableCmd `cmd:"" help:"Turn protection off in a repo"`
+	Prune   ReposPruneCmd   `cmd:"" help:"Forget repos which no longer exist"`
+}
+
+type ReposListCmd struct {
+	JSON bool `help:"Print repos as json" default:"false"`
+}
+
+type ReposEnableCmd struct {
+	Path string `arg:"" optional:"" default:"." help:"Path of the repo"`
+}
+
+type ReposDisableCmd struct {
+	Path string `arg:"" optional:"" default:"." help:"Path of the repo"`
+}
+
+type ReposPruneCmd struct{}
+
+// status of repos which are no longer where they were enrolled
+const repoMissing hooks.RepoStatus = "missing"
+
+type repoEntry struct {
+	registry.Repo
+	Status     hooks.RepoStatus `json:"status"`
+	LastPushAt time.Time        `json:"last_push_at,omitzero"`
+}
+
+func (c *ReposListCmd) Run(cfg *config.Config) error {
+	logger := context.Background().Logger()
+
+	repos, err := registry.New(cfg.Home()).List()
+	if err != nil {
+		return err
+	}
+
+	entries := []repoEntry{}
+	for _, repo := range repos {
+		entry := repoEntry{Repo: repo, Status: repoMissing}
+		if repo.Exists() {
+			err := inDir(repo.Path, func() error {
+				layout, err := git.ResolveLayout()
+				if err != nil {
+					return err
+				}
+				entry.LastPushAt = layout.LastPush()
+				entry.Status, err = hooks.CheckRepo(cfg.Home(), cfg.HookChainPolicy)
+				return err
+			})
+			if err != nil {
+				logger.Error(err, "Could not check repo: "+repo.Path)
+				entry.Status = "unknown"
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	if c.JSON {
+		out, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No repos set up yet. axi sets up a repo on its first git hook run.")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tREMOTE\tSTATUS\tLAST SCAN\tLAST PUSH")
+	for _, entry := range entries {
+		remote := entry.Remote
+		if remote == "" {
+			remote = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", entry.Path, remote, entry.Status,
+			formatTime(entry.LastScanAt), formatTime(entry.LastPushAt))
+	}
+	return w.Flush()
+}
+
+func (c *ReposEnableCmd) Run(cfg *config.Config, conn *grpc.ClientConn) error {
+	return inDir(c.Path, func() error {
+		repo, err := hooks.EnableRepo(cfg.Home(), cfg.HookChainPolicy)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Protection enabled in " + repo.Path)
+		reportRepoEvent(cfg, conn, repo, "repo_enabled")
+		return nil
+	})
+}
+
+func (c *ReposDisableCmd) Run(cfg *config.Config, conn *grpc.ClientConn) error {
+	return inDir(c.Path, func() error {
+		repo, err := hooks.DisableRepo(cfg.Home(), func(change string) {
+			fmt.Println(change)
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Println("Protection disabled in " + repo.Path)
+		reportRepoEvent(cfg, conn, repo, "repo_disabled")
+		return nil
+	})
+}
+
+func (c *ReposPruneCmd) Run(cfg *config.Config) error {
+	var pruned []registry.Repo
+	err := registry.New(cfg.Home()).Update(func(repos []registry.Repo) ([]registry.Repo, error) {
+		var kept []registry.Repo
+		for _, repo := range repos {
+			if repo.Exists() {
+				kept = append(kept, repo)
+			} else {
+				pruned = append(pruned, repo)
+			}
+		}
+		return kept, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, repo := range pruned {
+		fmt.Println("Removed " + repo.Path)
+	}
+	if len(pruned) == 0 {
+		fmt.Println("Nothing to prune")
+	}
+	return nil
+}
+
+// reportRepoEvent tells the backend protection was turned on or off in
+// a repo. Failing to do so does not fail the command.
+func reportRepoEvent(cfg *config.Config, conn *grpc.ClientConn, repo registry.Repo, event string) {
+	logger := context.Background().Logger()
+
+	if cfg.Offline {
+		return
+	}
+	client := pb.NewMetadataServiceClient(conn)
+	ctx, cancel := context.GRPCContext()
+	defer cancel()
+	_, err := client.RepoMetadata(ctx, &pb.MetadataRepoRequest{
+		RepoUrl: repo.Remote,
+		Metadata: utils.GetEventMetadataJson(cfg.Version, event, map[string]string{
+			"repo_id": repo.ID,
+			"path":    repo.Path,
+		}),
+	})
+	if err != nil {
+		logger.Error(err, "Could not report "+event)
+	}
+}
+
+// inDir runs fn from dir, then returns to the current directory
+func inDir(dir string, fn func() error) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if err := os.Chdir(dir); err != nil {
+		return err
+	}
+	defer os.Chdir(cwd)
+	return fn()
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
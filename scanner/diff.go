@@ -0,0 +1,135 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/axilock/axi/internal/context"
+	"github.com/axilock/axi/internal/git"
+)
+
+// DiffScanner scans only the lines added by the pushed commits, so a
+// secret is reported once, in the commit introducing it, and not again
+// for commits that merely move or delete it.
+//
+// Added lines are written to a scratch tree at their real line numbers
+// (one directory per commit) and handed to trufflehog's filesystem source.
+type DiffScanner struct {
+	trufflehog  *Trufflehog
+	findRenames bool
+}
+
+func NewDiffScanner(trufflehog *Trufflehog, findRenames bool) *DiffScanner {
+	return &DiffScanner{trufflehog: trufflehog, findRenames: findRenames}
+}
+
+func (d *DiffScanner) Run(ctx context.Context, dir, sinceCommit, branch string) ([]Secret, error) {
+	var logger = ctx.Logger().WithName("diff-scanner")
+
+	reader := git.NewObjectReader(dir)
+	defer reader.Close()
+
+	commits, err := reader.Commits(sinceCommit, branch)
+	if err != nil {
+		return nil, err
+	}
+	if len(commits) == 0 {
+		return nil, nil
+	}
+
+	// non empty only for partial clones, these are never fetched
+	missing, err := reader.MissingObjects(sinceCommit, branch)
+	if err != nil {
+		return nil, err
+	}
+
+	scratch, err := os.MkdirTemp("", "axi-diff-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(scratch)
+
+	byID := make(map[string]git.Commit)
+	var skipped []string
+	for _, commit := range commits {
+		if ctx.Err() != nil {
+			return nil, context.Cause(ctx)
+		}
+		additions, skippedFiles, err := reader.AddedLinesPresent(commit.ID, d.findRenames, missing)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range skippedFiles {
+			skipped = append(skipped, commit.ID[:min(len(commit.ID), 10)]+":"+file)
+		}
+		if len(additions) == 0 {
+			continue
+		}
+		byID[commit.ID] = commit
+		if err := writeAdditions(filepath.Join(scratch, commit.ID), additions); err != nil {
+			return nil, err
+		}
+	}
+
+	var partial error
+	if len(skipped) > 0 {
+		partial = &PartialScanError{
+			Reason:  "Partial clone, contents not available locally were not fetched",
+			Skipped: skipped,
+		}
+	}
+
+	if len(byID) == 0 {
+		logger.V(1).Info("No added lines to scan")
+		return nil, partial
+	}
+
+	found, err := d.trufflehog.RunFilesystem(ctx, scratch)
+	if err == nil {
+		err = partial
+	}
+
+	var secrets []Secret
+	for _, secret := range found {
+		id, file, ok := strings.Cut(secret.File, "/")
+		commit, known := byID[id]
+		if !ok || !known {
+			logger.Info("Dropping finding outside scanned commits: " + secret.File)
+			continue
+		}
+		secret.Commit = commit
+		secret.File = file
+		secrets = append(secrets, secret)
+	}
+	return secrets, err
+}
+
+// writeAdditions writes each file with only its added lines kept in place,
+// every other line left blank, so reported line numbers match the commit
+func writeAdditions(root string, additions []git.FileAdditions) error {
+	for _, file := range additions {
+		path := filepath.Join(root, filepath.FromSlash(file.Path))
+		if !strings.HasPrefix(path, root+string(filepath.Separator)) {
+			continue // refuse paths escaping the scratch dir
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return err
+		}
+
+		var content strings.Builder
+		lineNo := 1
+		for _, line := range file.Lines {
+			for ; lineNo < line.Line; lineNo++ {
+				content.WriteByte('\n')
+			}
+			content.WriteString(line.Text)
+			content.WriteByte('\n')
+			lineNo++
+		}
+		if err := os.WriteFile(path, []byte(content.String()), 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
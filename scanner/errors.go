@@ -1,6 +1,9 @@
 package scanner
 
-import "runtime"
+import (
+	"runtime"
+	"time"
+)
 
 type ErrTrufflehogNotInstalled struct {
 	installInstructions string
@@ -26,3 +29,12 @@ func (e *ErrTrufflehogNotInstalled) Error() string {
 		e.installInstructions + "\n" +
 		e.installCommand
 }
+
+type ErrScanTimeout struct {
+	Timeout time.Duration
+}
+
+func (e *ErrScanTimeout) Error() string {
+	return "Secret scan did not finish within " + e.Timeout.String() +
+		". You can raise scan_timeout in ~/.axi/config.yaml"
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package scanner
+
+import "os/exec"
+
+// killProcessTree falls back to killing only the scanner process
+func killProcessTree(cmd *exec.Cmd) {}
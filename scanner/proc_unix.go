@@ -0,0 +1,17 @@
+//go:build unix
+
+package scanner
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killProcessTree runs cmd in its own process group, so that cancelling
+// also kills the git processes spawned by the scanner
+func killProcessTree(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -3,7 +3,9 @@ package scanner
 import (
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/axilock/axi/internal/context"
 	"github.com/axilock/axi/internal/git"
 )
 
@@ -36,7 +38,9 @@ func (s *Secret) String() string {
 }
 
 type SecretScanner interface {
-	Run(dir, sinceCommit, branch string) ([]Secret, error)
+	// Run scans commits reachable from branch and not from sinceCommit.
+	// Scanning stops when ctx is done.
+	Run(ctx context.Context, dir, sinceCommit, branch string) ([]Secret, error)
 }
 
 type ScanError struct {
@@ -47,3 +51,18 @@ type ScanError struct {
 func (s *ScanError) Error() string {
 	return s.scanner + " faced errors. Results might be incomplete. " + s.reason
 }
+
+// PartialScanError is returned along with the results when parts of a push
+// could not be scanned, eg: file contents missing in partial clones
+type PartialScanError struct {
+	Reason  string
+	Skipped []string
+}
+
+func (p *PartialScanError) Error() string {
+	msg := "Partial scan. " + p.Reason
+	if len(p.Skipped) > 0 {
+		msg += fmt.Sprintf(". %d file(s) not scanned: %s", len(p.Skipped), strings.Join(p.Skipped, ", "))
+	}
+	return msg
+}
@@ -5,9 +5,12 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/axilock/axi/internal/context"
 	"github.com/axilock/axi/internal/git"
@@ -29,6 +32,10 @@ type TrufflehogResult struct {
 				Timestamp  git.Time `json:"timestamp"`
 				Line       int      `json:"line"`
 			} `json:"Git"`
+			Filesystem struct {
+				File string `json:"file"`
+				Line int    `json:"line"`
+			} `json:"Filesystem"`
 		} `json:"Data"`
 	} `json:"SourceMetadata"`
 	SourceID            int    `json:"SourceID"`
@@ -54,8 +61,35 @@ func NewTrufflehog(absPath string) *Trufflehog {
 }
 
 // branch and sinceCommit could be empty strings if not required
-func (t *Trufflehog) Run(dir, sinceCommit, branch string) ([]Secret, error) {
-	var logger = context.Background().Logger().WithName("trufflehog")
+func (t *Trufflehog) Run(ctx context.Context, dir, sinceCommit, branch string) ([]Secret, error) {
+	args := []string{
+		"git",
+		"file://.",
+	}
+
+	if sinceCommit != "" {
+		args = append(args, "--since-commit", sinceCommit)
+	}
+
+	if branch != "" {
+		args = append(args, "--branch", branch)
+	}
+
+	return t.run(ctx, dir, args...)
+}
+
+// RunFilesystem scans files under root instead of git history.
+// Results carry the file path relative to root in File.
+func (t *Trufflehog) RunFilesystem(ctx context.Context, root string) ([]Secret, error) {
+	secrets, err := t.run(ctx, root, "filesystem", ".")
+	for i := range secrets {
+		secrets[i].File = filepath.ToSlash(filepath.Clean(secrets[i].File))
+	}
+	return secrets, err
+}
+
+func (t *Trufflehog) run(ctx context.Context, dir string, sourceArgs ...string) ([]Secret, error) {
+	var logger = ctx.Logger().WithName("trufflehog")
 
 	var stdout bytes.Buffer
 	var stderr bytes.Buffer
@@ -65,39 +99,36 @@ func (t *Trufflehog) Run(dir, sinceCommit, branch string) ([]Secret, error) {
 	}
 	logger.V(1).Info("Trufflehog is at " + trufflehog)
 
-	args := []string{
-		trufflehog,
-		"git",
-		"file://.",
+	args := append([]string{trufflehog}, sourceArgs...)
+	args = append(args,
 		"--fail",
 		"--json",
 		"--force-skip-binaries",
 		"--force-skip-archives",
 		"--no-verification",
 		"--no-update",
-	}
-
-	if sinceCommit != "" {
-		args = append(args, "--since-commit", sinceCommit)
-	}
-
-	if branch != "" {
-		args = append(args, "--branch", branch)
-	}
-
-	cmd := exec.Cmd{
-		Path:   trufflehog,
-		Args:   args,
-		Stdout: &stdout,
-		Stderr: &stderr,
-		Dir:    dir,
-	}
+	)
+
+	cmd := exec.CommandContext(ctx, trufflehog)
+	cmd.Args = args
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Dir = dir
+	// never fetch objects missing in partial clones
+	cmd.Env = append(os.Environ(), git.NoLazyFetchEnv)
+	cmd.WaitDelay = 5 * time.Second
+	killProcessTree(cmd)
 
 	logger.Info("Running " + t.name + " with args " + strings.Join(args, " "))
 
 	err = cmd.Run()
 	logger.V(1).Info("command completed")
 
+	if ctx.Err() != nil {
+		logger.Info(t.name + " cancelled: " + ctx.Err().Error())
+		return trufflehogResultsToSecrets(&stdout), context.Cause(ctx)
+	}
+
 	if err != nil {
 		if e, ok := err.(*exec.ExitError); ok {
 			logger.Info(t.name + " exited with code " + strconv.Itoa(e.ExitCode()))
@@ -141,17 +172,23 @@ func trufflehogResultsToSecrets(raw *bytes.Buffer) []Secret {
 			continue
 		}
 
-		secrets = append(secrets, Secret{
+		data := result.SourceMetadata.Data
+		secret := Secret{
 			Commit: git.Commit{
-				ID:     result.SourceMetadata.Data.Git.Commit,
-				Author: result.SourceMetadata.Data.Git.Email,
-				Time:   result.SourceMetadata.Data.Git.Timestamp.Time,
+				ID:     data.Git.Commit,
+				Author: data.Git.Email,
+				Time:   data.Git.Timestamp.Time,
 			},
 			Value: result.Raw,
-			File:  result.SourceMetadata.Data.Git.File,
-			Line:  result.SourceMetadata.Data.Git.Line,
+			File:  data.Git.File,
+			Line:  data.Git.Line,
 			Type:  result.DetectorName,
-		})
+		}
+		if data.Filesystem.File != "" {
+			secret.File = data.Filesystem.File
+			secret.Line = data.Filesystem.Line
+		}
+		secrets = append(secrets, secret)
 	}
 
 	return secrets